@@ -0,0 +1,131 @@
+package nostr
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidAddress = errors.New("invalid address")
+
+// Address is the coordinate of a replaceable or addressable event, written as "<kind>:<pubkey>:<d-tag>"
+// in "a" tags. For replaceable events the identifier is empty. Address values are comparable, so they
+// can be used as map keys.
+type Address struct {
+	Kind       int
+	PubKey     string
+	Identifier string
+}
+
+// ParseAddress parses a "<kind>:<pubkey>:<d-tag>" coordinate. The identifier may contain ":".
+func ParseAddress(s string) (Address, error) {
+	spl := strings.SplitN(s, ":", 3)
+	if len(spl) != 3 {
+		return Address{}, fmt.Errorf("%w: expected <kind>:<pubkey>:<d-tag>", ErrInvalidAddress)
+	}
+
+	kind, err := strconv.Atoi(spl[0])
+	if err != nil || kind < 0 || kind > 65535 || strconv.Itoa(kind) != spl[0] {
+		return Address{}, fmt.Errorf("%w: invalid kind '%s'", ErrInvalidAddress, spl[0])
+	}
+	if !IsValid32ByteHex(spl[1]) {
+		return Address{}, fmt.Errorf("%w: invalid pubkey '%s'", ErrInvalidAddress, spl[1])
+	}
+
+	return Address{Kind: kind, PubKey: spl[1], Identifier: spl[2]}, nil
+}
+
+func (a Address) String() string {
+	return strconv.Itoa(a.Kind) + ":" + a.PubKey + ":" + a.Identifier
+}
+
+// Pointer returns an EntityPointer (what gets encoded as naddr) for this address.
+func (a Address) Pointer(relays ...string) EntityPointer {
+	return EntityPointer{PublicKey: a.PubKey, Kind: a.Kind, Identifier: a.Identifier, Relays: relays}
+}
+
+// Address returns the coordinate this pointer refers to, dropping the relays.
+func (ep EntityPointer) Address() Address {
+	return Address{Kind: ep.Kind, PubKey: ep.PublicKey, Identifier: ep.Identifier}
+}
+
+// Filter returns a filter that matches the events at this address. The "d" tag is only included
+// for addressable kinds.
+func (a Address) Filter() Filter {
+	filter := Filter{
+		Kinds:   []int{a.Kind},
+		Authors: []string{a.PubKey},
+	}
+	if IsAddressableKind(a.Kind) {
+		filter.Tags = TagMap{}.SetLiterals("d", a.Identifier)
+	}
+	return filter
+}
+
+// Matches checks if the event lives at this address.
+func (a Address) Matches(evt *Event) bool {
+	addr, ok := evt.Address()
+	return ok && addr == a
+}
+
+// Tag returns an "a" tag pointing to this address, with an optional relay hint.
+func (a Address) Tag(relay string) Tag {
+	if relay == "" {
+		return Tag{"a", a.String()}
+	}
+	return Tag{"a", a.String(), relay}
+}
+
+// Address returns the coordinate of the event, or false if it is neither replaceable nor addressable.
+func (evt *Event) Address() (Address, bool) {
+	switch {
+	case IsReplaceableKind(evt.Kind):
+		return Address{Kind: evt.Kind, PubKey: evt.PubKey}, true
+	case IsAddressableKind(evt.Kind):
+		return Address{Kind: evt.Kind, PubKey: evt.PubKey, Identifier: evt.Tags.GetD()}, true
+	default:
+		return Address{}, false
+	}
+}
+
+// Addresses iterates over all valid "a" tags, yielding each address and its relay hint (or "").
+func (tags Tags) Addresses() iter.Seq2[Address, string] {
+	return func(yield func(Address, string) bool) {
+		for _, tag := range tags {
+			if len(tag) < 2 || tag[0] != "a" {
+				continue
+			}
+			addr, err := ParseAddress(tag[1])
+			if err != nil {
+				continue
+			}
+			relay := ""
+			if len(tag) > 2 {
+				relay = tag[2]
+			}
+			if !yield(addr, relay) {
+				return
+			}
+		}
+	}
+}
+
+// GetAddressPointers returns all valid "a" tags as EntityPointers, with the relay hint if any.
+func (tags Tags) GetAddressPointers() []EntityPointer {
+	var pointers []EntityPointer
+	for addr, relay := range tags.Addresses() {
+		if relay == "" {
+			pointers = append(pointers, addr.Pointer())
+		} else {
+			pointers = append(pointers, addr.Pointer(relay))
+		}
+	}
+	return pointers
+}
+
+// AppendAddress appends an "a" tag for the given address unless there is one already.
+func (tags Tags) AppendAddress(addr Address, relay string) Tags {
+	return tags.AppendUnique(addr.Tag(relay))
+}
@@ -0,0 +1,84 @@
+package nostr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testAddressPubKey = "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+
+func TestParseAddress(t *testing.T) {
+	t.Parallel()
+
+	addr, err := ParseAddress("30023:" + testAddressPubKey + ":my:article")
+	require.NoError(t, err)
+	require.Equal(t, Address{Kind: 30023, PubKey: testAddressPubKey, Identifier: "my:article"}, addr)
+	require.Equal(t, "30023:"+testAddressPubKey+":my:article", addr.String())
+
+	addr, err = ParseAddress("0:" + testAddressPubKey + ":")
+	require.NoError(t, err)
+	require.Equal(t, Address{Kind: 0, PubKey: testAddressPubKey}, addr)
+
+	for _, bad := range []string{
+		"",
+		"30023:" + testAddressPubKey,
+		"abc:" + testAddressPubKey + ":x",
+		"-1:" + testAddressPubKey + ":x",
+		"070000:" + testAddressPubKey + ":x",
+		"030023:" + testAddressPubKey + ":x",
+		"30023:" + testAddressPubKey[1:] + ":x",
+		"30023:" + "3BF0C63FCB93463407AF97A5E5EE64FA883D107EF9E558472C4EB9AAAEFA459D" + ":x",
+	} {
+		_, err := ParseAddress(bad)
+		require.ErrorIs(t, err, ErrInvalidAddress, bad)
+	}
+}
+
+func TestEventAddress(t *testing.T) {
+	t.Parallel()
+
+	article := &Event{Kind: KindArticle, PubKey: testAddressPubKey, Tags: Tags{{"d", "hello"}}}
+	addr, ok := article.Address()
+	require.True(t, ok)
+	require.Equal(t, Address{Kind: KindArticle, PubKey: testAddressPubKey, Identifier: "hello"}, addr)
+	require.True(t, addr.Matches(article))
+	require.True(t, addr.Filter().Matches(article))
+	require.Equal(t, addr, addr.Pointer("wss://relay.com").Address())
+
+	profile := &Event{Kind: KindProfileMetadata, PubKey: testAddressPubKey, Tags: Tags{{"d", "ignored"}}}
+	addr, ok = profile.Address()
+	require.True(t, ok)
+	require.Equal(t, Address{Kind: KindProfileMetadata, PubKey: testAddressPubKey}, addr)
+	require.Nil(t, addr.Filter().Tags)
+	require.True(t, addr.Filter().Matches(profile))
+
+	_, ok = (&Event{Kind: KindTextNote}).Address()
+	require.False(t, ok)
+}
+
+func TestTagsAddresses(t *testing.T) {
+	t.Parallel()
+
+	a := Address{Kind: 30617, PubKey: testAddressPubKey, Identifier: "repo"}
+	b := Address{Kind: 30023, PubKey: testAddressPubKey, Identifier: ""}
+
+	tags := Tags{{"a", "garbage"}, {"e", "x"}}
+	tags = tags.AppendAddress(a, "wss://relay.com")
+	tags = tags.AppendAddress(b, "")
+	tags = tags.AppendAddress(a, "wss://other.com")
+	require.Len(t, tags, 4)
+	require.Equal(t, Tag{"a", a.String(), "wss://relay.com"}, tags[2])
+	require.Equal(t, Tag{"a", b.String()}, tags[3])
+
+	var found []Address
+	var relays []string
+	for addr, relay := range tags.Addresses() {
+		found = append(found, addr)
+		relays = append(relays, relay)
+	}
+	require.Equal(t, []Address{a, b}, found)
+	require.Equal(t, []string{"wss://relay.com", ""}, relays)
+
+	require.Equal(t, []EntityPointer{a.Pointer("wss://relay.com"), b.Pointer()}, tags.GetAddressPointers())
+}
@@ -0,0 +1,467 @@
+package nostr
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Coalescer sits on top of a SimplePool and combines many logical subscriptions to the same relays into
+// a single wire-level REQ per relay, using Filters.Merge(). Events are fanned out to each logical subscriber
+// whose filters match them. Whenever subscribers come and go the merged REQ is re-issued (after a small delay,
+// so changes that happen together are batched) and closed when nobody is interested in a relay anymore.
+// Subscribers that already got the stored events from a relay are re-issued with a "since" so they
+// don't get them again. If a relay fails or disconnects it is retried with an increasing interval.
+// Subscribers that have more than 10000 events waiting to be read are ended, so they don't hold memory forever.
+type Coalescer struct {
+	pool          *SimplePool
+	delay         time.Duration
+	retryInterval time.Duration
+	maxQueue      int // events a subscriber can have waiting to be read
+
+	mu     sync.Mutex
+	relays map[string]*coalescedRelay
+}
+
+type coalescedRelay struct {
+	url       string
+	subs      map[*CoalescedSubscription]struct{}
+	rebuildMu sync.Mutex
+	timer     *time.Timer
+	filters   Filters
+	cancel    context.CancelFunc
+
+	// subscribers that got an EOSE from this relay and the last time we heard from it, so the REQ
+	// is re-issued for them with a "since"
+	stored   map[*CoalescedSubscription]struct{}
+	lastSeen Timestamp
+
+	retry    time.Duration // zero while things are working
+	authedOn *Relay        // so we don't keep doing AUTH again and again on the same connection
+}
+
+// CoalescedSubscription is a logical subscription managed by a Coalescer.
+type CoalescedSubscription struct {
+	Filters Filters
+
+	// Events emits all matching events from all relays, deduplicated. It is closed when the subscription ends.
+	Events chan RelayEvent
+
+	// EndOfStoredEvents is closed when all relays have sent an EOSE for a REQ that included this subscription
+	// (or failed).
+	EndOfStoredEvents chan struct{}
+
+	// Context will be .Done() when the subscription ends.
+	Context context.Context
+
+	coalescer *Coalescer
+	urls      []string
+	cancel    context.CancelFunc
+	dedup     Deduplicator
+
+	// events and EOSEs are queued here so a slow subscriber doesn't hold the others
+	queueMu sync.Mutex
+	queue   []coalescedItem
+	wake    chan struct{}
+
+	eoseMu      sync.Mutex
+	pendingEose map[string]struct{}
+}
+
+type coalescedItem struct {
+	ie   RelayEvent
+	eose string // relay url, if this is an EOSE
+}
+
+// NewCoalescer creates a Coalescer that will use the given pool to connect to relays. delay is how long it
+// waits after a change in the subscribers before re-issuing the REQ, if zero it defaults to 50ms.
+func NewCoalescer(pool *SimplePool, delay time.Duration) *Coalescer {
+	if delay == 0 {
+		delay = 50 * time.Millisecond
+	}
+	return &Coalescer{
+		pool:          pool,
+		delay:         delay,
+		retryInterval: 3 * time.Second,
+		maxQueue:      10000,
+		relays:        make(map[string]*coalescedRelay),
+	}
+}
+
+// Subscribe creates a logical subscription to the given relays. It ends when ctx is canceled or
+// when Close() is called.
+func (c *Coalescer) Subscribe(ctx context.Context, urls []string, filters Filters) *CoalescedSubscription {
+	ctx, cancel := context.WithCancel(ctx)
+
+	sub := &CoalescedSubscription{
+		Filters:           filters,
+		Events:            make(chan RelayEvent),
+		EndOfStoredEvents: make(chan struct{}),
+		Context:           ctx,
+		coalescer:         c,
+		cancel:            cancel,
+		pendingEose:       make(map[string]struct{}, len(urls)),
+		wake:              make(chan struct{}, 1),
+	}
+	if c.pool.newDeduplicator != nil {
+		sub.dedup = c.pool.newDeduplicator()
+	} else {
+		sub.dedup = newEOSETimeWindowDeduplicator(seenAlreadyDropTick)
+	}
+
+	c.mu.Lock()
+	for _, url := range urls {
+		nm := NormalizeURL(url)
+		if _, ok := sub.pendingEose[nm]; ok {
+			continue
+		}
+		sub.urls = append(sub.urls, nm)
+		sub.pendingEose[nm] = struct{}{}
+
+		cr, ok := c.relays[nm]
+		if !ok {
+			cr = &coalescedRelay{
+				url:    nm,
+				subs:   make(map[*CoalescedSubscription]struct{}),
+				stored: make(map[*CoalescedSubscription]struct{}),
+			}
+			c.relays[nm] = cr
+		}
+		cr.subs[sub] = struct{}{}
+		c.scheduleRebuild(cr)
+	}
+	c.mu.Unlock()
+
+	if len(sub.urls) == 0 {
+		close(sub.EndOfStoredEvents)
+	}
+
+	go sub.run()
+
+	return sub
+}
+
+// WireFilters returns the filters currently being sent to the given relay, mostly useful for debugging.
+func (c *Coalescer) WireFilters(url string) Filters {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cr, ok := c.relays[NormalizeURL(url)]; ok {
+		return cr.filters
+	}
+	return nil
+}
+
+// Close ends the subscription. The underlying REQs will be re-issued without its filters.
+func (sub *CoalescedSubscription) Close() {
+	sub.cancel()
+}
+
+func (c *Coalescer) remove(sub *CoalescedSubscription) {
+	c.mu.Lock()
+	for _, url := range sub.urls {
+		if cr, ok := c.relays[url]; ok {
+			delete(cr.subs, sub)
+			delete(cr.stored, sub)
+			c.scheduleRebuild(cr)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// scheduleRebuild must be called with c.mu held.
+func (c *Coalescer) scheduleRebuild(cr *coalescedRelay) {
+	if cr.timer == nil {
+		cr.timer = time.AfterFunc(c.delay, func() { c.rebuild(cr) })
+	}
+}
+
+// retryLater schedules a rebuild after the relay failed or disconnected, waiting longer each time.
+func (c *Coalescer) retryLater(cr *coalescedRelay) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cr.retry == 0 {
+		cr.retry = c.retryInterval
+	} else {
+		cr.retry = cr.retry * 17 / 10
+	}
+	if cr.timer == nil && len(cr.subs) > 0 {
+		cr.timer = time.AfterFunc(cr.retry, func() { c.rebuild(cr) })
+	}
+}
+
+// rebuild closes the current REQ to a relay and issues a new one with the merged filters of all current subscribers.
+func (c *Coalescer) rebuild(cr *coalescedRelay) {
+	cr.rebuildMu.Lock()
+	defer cr.rebuildMu.Unlock()
+
+	c.mu.Lock()
+	cr.timer = nil
+	subs := make([]*CoalescedSubscription, 0, len(cr.subs))
+	var filters Filters
+	for sub := range cr.subs {
+		subs = append(subs, sub)
+		if _, ok := cr.stored[sub]; ok {
+			filters = append(filters, liveFilters(sub.Filters, cr.lastSeen)...)
+		} else {
+			filters = append(filters, sub.Filters...)
+		}
+	}
+	oldCancel := cr.cancel
+	cr.cancel = nil
+	if len(subs) == 0 || len(filters) == 0 {
+		if len(subs) == 0 && c.relays[cr.url] == cr {
+			delete(c.relays, cr.url)
+		}
+		cr.filters = nil
+		c.mu.Unlock()
+		if oldCancel != nil {
+			oldCancel()
+		}
+		return
+	}
+	c.mu.Unlock()
+
+	merged := filters.Merge()
+	ctx, cancel := context.WithCancel(c.pool.Context)
+
+	var wire *Subscription
+	relay, err := c.pool.EnsureRelay(cr.url)
+	if err == nil {
+		wire, err = relay.Subscribe(ctx, merged, WithLabel("coalesced"))
+	}
+
+	if oldCancel != nil {
+		oldCancel()
+	}
+
+	if err != nil {
+		cancel()
+		debugLogf("coalescer failed to subscribe to %s: %s", cr.url, err)
+		c.mu.Lock()
+		cr.filters = nil
+		c.mu.Unlock()
+
+		// don't hold the subscribers waiting for this relay, we'll keep trying in the background
+		for _, sub := range subs {
+			sub.markEose(cr.url)
+		}
+		c.retryLater(cr)
+		return
+	}
+
+	c.mu.Lock()
+	cr.cancel = cancel
+	cr.filters = merged
+	c.mu.Unlock()
+
+	go c.handleWire(ctx, cr, relay, wire, subs)
+}
+
+// liveFilters returns the filters for a subscriber that already got the stored events, so only events
+// from since on are requested. Filters that can't match anything newer are dropped.
+func liveFilters(filters Filters, since Timestamp) Filters {
+	live := make(Filters, 0, len(filters))
+	for _, filter := range filters {
+		if filter.Until != nil && *filter.Until < since {
+			continue
+		}
+		if filter.Since == nil || *filter.Since < since {
+			filter.Since = &since
+		}
+		live = append(live, filter)
+	}
+	return live
+}
+
+func (c *Coalescer) handleWire(
+	ctx context.Context,
+	cr *coalescedRelay,
+	relay *Relay,
+	wire *Subscription,
+	covered []*CoalescedSubscription,
+) {
+	for {
+		select {
+		case evt, more := <-wire.Events:
+			if !more {
+				c.handleWireEnd(ctx, cr)
+				return
+			}
+
+			c.mu.Lock()
+			cr.lastSeen = Now()
+			c.mu.Unlock()
+
+			// the store gets the event as it was received and verified, policies may rewrite it
+			received := c.pool.copyForStore(evt)
+			ie, ok := c.pool.applyEventPolicies(RelayEvent{Event: evt, Relay: relay})
+			if !ok {
+				continue
+			}
+			for _, mh := range c.pool.eventMiddleware {
+				mh(ie)
+			}
+
+			c.mu.Lock()
+			subs := make([]*CoalescedSubscription, 0, len(cr.subs))
+			for sub := range cr.subs {
+				subs = append(subs, sub)
+			}
+			c.mu.Unlock()
+
+			saved := false
+			for _, sub := range subs {
+				if sub.matches(cr.url, ie.Event) {
+					if !saved {
+						c.pool.saveToStore(wire.Context, received)
+						saved = true
+					}
+					sub.dispatch(ie)
+				}
+			}
+		case <-wire.EndOfStoredEvents:
+			c.mu.Lock()
+			cr.lastSeen = Now()
+			cr.retry = 0 // this is a good subscription
+			for _, sub := range covered {
+				if _, ok := cr.subs[sub]; ok {
+					cr.stored[sub] = struct{}{}
+				}
+			}
+			c.mu.Unlock()
+
+			for _, sub := range covered {
+				sub.push(coalescedItem{eose: cr.url})
+			}
+		case reason := <-wire.ClosedReason:
+			c.mu.Lock()
+			tryAuth := strings.HasPrefix(reason, "auth-required:") && c.pool.canAuth(relay) && cr.authedOn != relay
+			if tryAuth {
+				cr.authedOn = relay
+			}
+			c.mu.Unlock()
+			if tryAuth {
+				// relay is requesting auth. if we can we will perform auth and try again
+				if err := c.pool.authenticate(wire.Context, relay); err == nil {
+					c.mu.Lock()
+					c.scheduleRebuild(cr)
+					c.mu.Unlock()
+					return
+				}
+			}
+			log.Printf("CLOSED from %s: '%s'\n", cr.url, reason)
+			for _, sub := range covered {
+				sub.markEose(cr.url)
+			}
+			return
+		case <-wire.Context.Done():
+			c.handleWireEnd(ctx, cr)
+			return
+		}
+	}
+}
+
+// handleWireEnd is called when the wire subscription ends, if that wasn't caused by us replacing it with
+// another it means the connection was lost, so we try to reconnect.
+func (c *Coalescer) handleWireEnd(ctx context.Context, cr *coalescedRelay) {
+	if ctx.Err() != nil {
+		return
+	}
+	debugLogf("coalescer lost connection to %s, reconnecting", cr.url)
+	c.retryLater(cr)
+}
+
+func (sub *CoalescedSubscription) matches(url string, evt *Event) bool {
+	sub.eoseMu.Lock()
+	_, pending := sub.pendingEose[url]
+	sub.eoseMu.Unlock()
+
+	if pending {
+		return sub.Filters.Match(evt)
+	}
+	// after EOSE we ignore timestamps, just like Subscription does
+	return sub.Filters.MatchIgnoringTimestampConstraints(evt)
+}
+
+func (sub *CoalescedSubscription) dispatch(ie RelayEvent) {
+	if seenBefore(sub.dedup, ie.ID, ie.Relay.URL) {
+		return
+	}
+	sub.push(coalescedItem{ie: ie})
+}
+
+func (sub *CoalescedSubscription) push(item coalescedItem) {
+	sub.queueMu.Lock()
+	if sub.Context.Err() != nil {
+		sub.queueMu.Unlock()
+		return
+	}
+	if item.eose == "" && len(sub.queue) >= sub.coalescer.maxQueue {
+		sub.queueMu.Unlock()
+		log.Printf("coalesced subscription to %v ended after falling %d events behind\n", sub.urls, sub.coalescer.maxQueue)
+		sub.cancel()
+		return
+	}
+	sub.queue = append(sub.queue, item)
+	sub.queueMu.Unlock()
+
+	select {
+	case sub.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers queued items to the subscriber in order until the subscription ends.
+func (sub *CoalescedSubscription) run() {
+	defer func() {
+		sub.coalescer.remove(sub)
+		close(sub.Events)
+	}()
+
+	for {
+		sub.queueMu.Lock()
+		if len(sub.queue) == 0 {
+			sub.queueMu.Unlock()
+			select {
+			case <-sub.wake:
+				continue
+			case <-sub.Context.Done():
+				return
+			}
+		}
+		item := sub.queue[0]
+		sub.queue[0] = coalescedItem{}
+		sub.queue = sub.queue[1:]
+		sub.queueMu.Unlock()
+
+		if item.eose != "" {
+			sub.markEose(item.eose)
+			continue
+		}
+
+		select {
+		case sub.Events <- item.ie:
+		case <-sub.Context.Done():
+			return
+		}
+	}
+}
+
+func (sub *CoalescedSubscription) markEose(url string) {
+	sub.eoseMu.Lock()
+	defer sub.eoseMu.Unlock()
+
+	if _, ok := sub.pendingEose[url]; !ok {
+		return
+	}
+	delete(sub.pendingEose, url)
+	if len(sub.pendingEose) == 0 {
+		close(sub.EndOfStoredEvents)
+		if dedup, ok := sub.dedup.(*TimeWindowDeduplicator); ok {
+			dedup.gotEOSE()
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package nostr
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"maps"
+	"net"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestCoalescer(t *testing.T) {
+	priv1, pub1 := makeKeyPair(t)
+	priv2, pub2 := makeKeyPair(t)
+
+	profiles := map[string]*Event{}
+	for _, sk := range []string{priv1, priv2} {
+		evt := &Event{Kind: KindProfileMetadata, CreatedAt: Now(), Content: "{}"}
+		require.NoError(t, evt.Sign(sk))
+		profiles[evt.PubKey] = evt
+	}
+
+	reqs, ws := newCoalescingRelay(t, func() []*Event { return slices.Collect(maps.Values(profiles)) }, nil)
+	defer ws.Close()
+
+	ctx := context.Background()
+	c := NewCoalescer(NewSimplePool(ctx), 20*time.Millisecond)
+
+	sub1 := c.Subscribe(ctx, []string{ws.URL}, Filters{{Kinds: []int{0}, Authors: []string{pub1}}})
+	sub2 := c.Subscribe(ctx, []string{ws.URL}, Filters{{Kinds: []int{0}, Authors: []string{pub2}}})
+
+	// both subscriptions become a single REQ
+	select {
+	case filters := <-reqs:
+		require.Len(t, filters, 1)
+		require.ElementsMatch(t, []string{pub1, pub2}, filters[0].Authors)
+	case <-time.After(2 * time.Second):
+		t.Fatal("relay got no REQ")
+	}
+
+	for _, tc := range []struct {
+		sub *CoalescedSubscription
+		pk  string
+	}{{sub1, pub1}, {sub2, pub2}} {
+		select {
+		case ie := <-tc.sub.Events:
+			require.Equal(t, tc.pk, ie.PubKey)
+		case <-time.After(2 * time.Second):
+			t.Fatal("subscriber got no event")
+		}
+		select {
+		case <-tc.sub.EndOfStoredEvents:
+		case <-time.After(2 * time.Second):
+			t.Fatal("subscriber got no EOSE")
+		}
+	}
+
+	// when one leaves the REQ is re-issued without it
+	sub1.Close()
+	select {
+	case filters := <-reqs:
+		require.Len(t, filters, 1)
+		require.Equal(t, []string{pub2}, filters[0].Authors)
+	case <-time.After(2 * time.Second):
+		t.Fatal("relay got no new REQ")
+	}
+	_, more := <-sub1.Events
+	require.False(t, more)
+
+	sub2.Close()
+	require.Eventually(t, func() bool { return c.WireFilters(ws.URL) == nil }, time.Second, 10*time.Millisecond)
+}
+
+// newCoalescingRelay starts a relay that answers every REQ with the matching events returned by events
+// and an EOSE, sending the filters it got to the returned channel. When drop is given, every value sent
+// to it closes the current connection.
+func newCoalescingRelay(t *testing.T, events func() []*Event, drop chan struct{}) (chan Filters, *httptest.Server) {
+	reqs := make(chan Filters, 10)
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		if drop != nil {
+			go func() {
+				<-drop
+				conn.Close()
+			}()
+		}
+
+		for {
+			var raw []stdjson.RawMessage
+			if err := websocket.JSON.Receive(conn, &raw); err != nil {
+				return
+			}
+			var typ, id string
+			json.Unmarshal(raw[0], &typ)
+			if typ != "REQ" {
+				continue
+			}
+			json.Unmarshal(raw[1], &id)
+
+			var filters Filters
+			for _, f := range raw[2:] {
+				var filter Filter
+				json.Unmarshal(f, &filter)
+				filters = append(filters, filter)
+			}
+			reqs <- filters
+
+			for _, evt := range events() {
+				if filters.Match(evt) {
+					websocket.JSON.Send(conn, []any{"EVENT", id, evt})
+				}
+			}
+			websocket.JSON.Send(conn, []any{"EOSE", id})
+		}
+	})
+	return reqs, ws
+}
+
+func receiveREQ(t *testing.T, reqs chan Filters) Filters {
+	t.Helper()
+	select {
+	case filters := <-reqs:
+		return filters
+	case <-time.After(5 * time.Second):
+		t.Fatal("relay got no REQ")
+		return nil
+	}
+}
+
+func requireEvent(t *testing.T, sub *CoalescedSubscription, pubkey string) {
+	t.Helper()
+	select {
+	case ie := <-sub.Events:
+		require.Equal(t, pubkey, ie.PubKey)
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscriber got no event")
+	}
+}
+
+func requireNoEvent(t *testing.T, sub *CoalescedSubscription) {
+	t.Helper()
+	select {
+	case ie := <-sub.Events:
+		t.Fatalf("subscriber got an unexpected event from %s", ie.PubKey)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func makeOldProfile(t *testing.T) *Event {
+	evt := &Event{Kind: KindProfileMetadata, CreatedAt: Now() - 60, Content: "{}"}
+	require.NoError(t, evt.Sign(GeneratePrivateKey()))
+	return evt
+}
+
+func TestCoalescerJoinWhileLive(t *testing.T) {
+	profile1, profile2 := makeOldProfile(t), makeOldProfile(t)
+	reqs, ws := newCoalescingRelay(t, func() []*Event { return []*Event{profile1, profile2} }, nil)
+	defer ws.Close()
+
+	ctx := context.Background()
+	c := NewCoalescer(NewSimplePool(ctx), 20*time.Millisecond)
+
+	sub1 := c.Subscribe(ctx, []string{ws.URL}, Filters{{Kinds: []int{0}, Authors: []string{profile1.PubKey}}})
+	defer sub1.Close()
+	receiveREQ(t, reqs)
+	requireEvent(t, sub1, profile1.PubKey)
+	<-sub1.EndOfStoredEvents
+
+	// the new subscriber gets the stored events, the old one only asks for new ones
+	sub2 := c.Subscribe(ctx, []string{ws.URL}, Filters{{Kinds: []int{0}, Authors: []string{profile2.PubKey}}})
+	for _, filter := range receiveREQ(t, reqs) {
+		if slices.Contains(filter.Authors, profile1.PubKey) {
+			require.NotNil(t, filter.Since)
+			require.Greater(t, *filter.Since, profile1.CreatedAt)
+		}
+		if slices.Contains(filter.Authors, profile2.PubKey) {
+			require.Nil(t, filter.Since)
+		}
+	}
+	requireEvent(t, sub2, profile2.PubKey)
+	<-sub2.EndOfStoredEvents
+	requireNoEvent(t, sub1)
+
+	// and nothing is replayed when someone leaves
+	sub2.Close()
+	filters := receiveREQ(t, reqs)
+	require.Len(t, filters, 1)
+	require.NotNil(t, filters[0].Since)
+	requireNoEvent(t, sub1)
+}
+
+func TestCoalescerReconnect(t *testing.T) {
+	profile := makeOldProfile(t)
+	drop := make(chan struct{})
+	var fresh atomic.Pointer[Event]
+	reqs, ws := newCoalescingRelay(t, func() []*Event {
+		events := []*Event{profile}
+		if evt := fresh.Load(); evt != nil {
+			events = append(events, evt)
+		}
+		return events
+	}, drop)
+	defer ws.Close()
+
+	ctx := context.Background()
+	c := NewCoalescer(NewSimplePool(ctx), 20*time.Millisecond)
+	c.retryInterval = 50 * time.Millisecond
+
+	sub := c.Subscribe(ctx, []string{ws.URL}, Filters{{Kinds: []int{0}}})
+	defer sub.Close()
+	receiveREQ(t, reqs)
+	requireEvent(t, sub, profile.PubKey)
+	<-sub.EndOfStoredEvents
+
+	// a new profile appears while we're disconnected
+	evt := &Event{Kind: KindProfileMetadata, CreatedAt: Now() + 1, Content: "{}"}
+	require.NoError(t, evt.Sign(GeneratePrivateKey()))
+	fresh.Store(evt)
+	drop <- struct{}{}
+
+	// we come back only asking for what we haven't seen
+	filters := receiveREQ(t, reqs)
+	require.Len(t, filters, 1)
+	require.NotNil(t, filters[0].Since)
+	requireEvent(t, sub, evt.PubKey)
+	requireNoEvent(t, sub)
+}
+
+func TestCoalescerRetriesFailedRelay(t *testing.T) {
+	// get a free address for a relay that isn't running yet
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	ctx := context.Background()
+	c := NewCoalescer(NewSimplePool(ctx), 20*time.Millisecond)
+	c.retryInterval = 100 * time.Millisecond
+
+	// the relay is down, so we get an EOSE anyway
+	sub := c.Subscribe(ctx, []string{"ws://" + addr}, Filters{{Kinds: []int{0}}})
+	defer sub.Close()
+	select {
+	case <-sub.EndOfStoredEvents:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscriber got no EOSE")
+	}
+
+	// and when it comes up we get its events
+	profile := makeOldProfile(t)
+	reqs, ws := newCoalescingRelay(t, func() []*Event { return []*Event{profile} }, nil)
+	ws.Close()
+	ws = httptest.NewUnstartedServer(ws.Config.Handler)
+	ws.Listener, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	ws.Start()
+	defer ws.Close()
+
+	filters := receiveREQ(t, reqs)
+	require.Nil(t, filters[0].Since, "we never got the stored events")
+	requireEvent(t, sub, profile.PubKey)
+}
+
+func TestCoalescerEndsStalledSubscriber(t *testing.T) {
+	var profiles []*Event
+	for range 10 {
+		profiles = append(profiles, makeOldProfile(t))
+	}
+	reqs, ws := newCoalescingRelay(t, func() []*Event { return profiles }, nil)
+	defer ws.Close()
+
+	ctx := context.Background()
+	c := NewCoalescer(NewSimplePool(ctx), 20*time.Millisecond)
+	c.maxQueue = 3
+
+	// nobody reads this one
+	stalled := c.Subscribe(ctx, []string{ws.URL}, Filters{{Kinds: []int{0}}})
+	receiveREQ(t, reqs)
+	select {
+	case <-stalled.Context.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled subscriber wasn't ended")
+	}
+	for range stalled.Events {
+	}
+}
@@ -37,7 +37,7 @@ func NewConnection(ctx context.Context, url string, requestHeader http.Header, t
 		},
 		TLSConfig: tlsConfig,
 	}
-	conn, _, hs, err := dialer.Dial(ctx, url)
+	conn, br, hs, err := dialer.Dial(ctx, url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to dial: %w", err)
 	}
@@ -63,9 +63,16 @@ func NewConnection(ctx context.Context, url string, requestHeader http.Header, t
 		})
 	}
 
+	// the relay may have sent something (like an AUTH challenge) right after the handshake,
+	// in that case it will be buffered in br, so we must read from it instead
+	var source io.Reader = conn
+	if br != nil {
+		source = br
+	}
+
 	controlHandler := wsutil.ControlFrameHandler(conn, ws.StateClientSide)
 	reader := &wsutil.Reader{
-		Source:         conn,
+		Source:         source,
 		State:          state,
 		OnIntermediate: controlHandler,
 		CheckUTF8:      false,
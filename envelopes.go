@@ -18,6 +18,11 @@ var (
 )
 
 func ParseMessage(message []byte) (Envelope, error) {
+	return parseMessage(message, false)
+}
+
+// parseMessage is like ParseMessage, but keepRaw makes events keep their original JSON.
+func parseMessage(message []byte, keepRaw bool) (Envelope, error) {
 	firstComma := bytes.Index(message, []byte{','})
 	if firstComma == -1 {
 		return nil, ErrMessageUnknown
@@ -27,7 +32,7 @@ func ParseMessage(message []byte) (Envelope, error) {
 	var v Envelope
 	switch {
 	case bytes.Contains(label, []byte("EVENT")):
-		v = &EventEnvelope{}
+		v = &EventEnvelope{keepRaw: keepRaw}
 	case bytes.Contains(label, []byte("REQ")):
 		v = &ReqEnvelope{}
 	case bytes.Contains(label, []byte("COUNT")):
@@ -78,6 +83,8 @@ var (
 type EventEnvelope struct {
 	SubscriptionID *string
 	Events         []*Event
+
+	keepRaw bool
 }
 
 func (_ EventEnvelope) Label() string { return "EVENT" }
@@ -98,7 +105,7 @@ func (v *EventEnvelope) UnmarshalJSON(data []byte) error {
 	case 2:
 		var ev Event
 
-		err := easyjson.Unmarshal([]byte(arr[1].Raw), &ev)
+		err := v.unmarshalEvent([]byte(arr[1].Raw), &ev)
 		if err == nil {
 			v.Events = []*Event{&ev}
 
@@ -120,7 +127,7 @@ func (v *EventEnvelope) UnmarshalJSON(data []byte) error {
 		v.Events = make([]*Event, 0, len(jsonEvents))
 		for i := range jsonEvents {
 			var ev Event
-			if err := easyjson.Unmarshal([]byte(jsonEvents[i].Raw), &ev); err != nil {
+			if err := v.unmarshalEvent([]byte(jsonEvents[i].Raw), &ev); err != nil {
 				return fmt.Errorf("%w -- on event %d", err, i)
 			}
 			v.Events = append(v.Events, &ev)
@@ -129,6 +136,13 @@ func (v *EventEnvelope) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (v *EventEnvelope) unmarshalEvent(data []byte, evt *Event) error {
+	if v.keepRaw {
+		return evt.UnmarshalJSONWithRaw(data)
+	}
+	return easyjson.Unmarshal(data, evt)
+}
+
 func (v EventEnvelope) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{NoEscapeHTML: true}
 	w.RawString(`["EVENT",`)
@@ -140,7 +154,12 @@ func (v EventEnvelope) MarshalJSON() ([]byte, error) {
 	}
 
 	for i := range v.Events {
-		v.Events[i].MarshalEasyJSON(&w)
+		if raw, ok := v.Events[i].RawJSON(); ok {
+			// send exactly what we got
+			w.Raw(raw, nil)
+		} else {
+			v.Events[i].MarshalEasyJSON(&w)
+		}
 		if i < len(v.Events)-1 {
 			w.RawByte(',')
 		}
@@ -193,6 +212,7 @@ type CountEnvelope struct {
 	Filters
 	Count       *int64
 	HyperLogLog []byte
+	Approximate bool
 }
 
 func (_ CountEnvelope) Label() string { return "COUNT" }
@@ -210,11 +230,13 @@ func (v *CountEnvelope) UnmarshalJSON(data []byte) error {
 	v.SubscriptionID = arr[1].Str
 
 	var countResult struct {
-		Count *int64 `json:"count"`
-		HLL   string `json:"hll"`
+		Count       *int64 `json:"count"`
+		HLL         string `json:"hll"`
+		Approximate bool   `json:"approximate"`
 	}
 	if err := json.Unmarshal([]byte(arr[2].Raw), &countResult); err == nil && countResult.Count != nil {
 		v.Count = countResult.Count
+		v.Approximate = countResult.Approximate
 		if len(countResult.HLL) == 512 {
 			v.HyperLogLog, err = hex.DecodeString(countResult.HLL)
 			if err != nil {
@@ -246,6 +268,9 @@ func (v CountEnvelope) MarshalJSON() ([]byte, error) {
 	if v.Count != nil {
 		w.RawString(`,{"count":`)
 		w.RawString(strconv.FormatInt(*v.Count, 10))
+		if v.Approximate {
+			w.RawString(`,"approximate":true`)
+		}
 		if v.HyperLogLog != nil {
 			w.RawString(`,"hll":"`)
 			hllHex := make([]byte, 512)
@@ -70,6 +70,17 @@ func TestCountEnvelopeEncodingAndDecoding(t *testing.T) {
 	res, err := json.Marshal(env)
 	require.NoError(t, err)
 	require.Equal(t, countEnv, string(res))
+
+	countEnv = `["COUNT","z",{"count":93412452,"approximate":true}]`
+	env = CountEnvelope{}
+	err = json.Unmarshal([]byte(countEnv), &env)
+	require.NoError(t, err)
+	require.Equal(t, int64(93412452), *env.Count)
+	require.True(t, env.Approximate)
+
+	res, err = json.Marshal(env)
+	require.NoError(t, err)
+	require.Equal(t, countEnv, string(res))
 }
 
 func TestOKEnvelopeEncodingAndDecoding(t *testing.T) {
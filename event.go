@@ -3,7 +3,9 @@ package nostr
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"fmt"
+	"slices"
+	"strconv"
+	"sync"
 
 	"github.com/mailru/easyjson"
 )
@@ -16,6 +18,9 @@ type Event struct {
 	Tags      Tags
 	Content   string
 	Sig       string
+
+	// raw is only set when the event is decoded with UnmarshalJSONWithRaw, see RawJSON.
+	raw *rawJSON
 }
 
 // Event Stringer interface, just returns the raw JSON as a string.
@@ -26,14 +31,32 @@ func (evt Event) String() string {
 
 // GetID serializes and returns the event ID as a string.
 func (evt *Event) GetID() string {
-	h := sha256.Sum256(evt.Serialize())
+	h := evt.ComputeID()
 	return hex.EncodeToString(h[:])
 }
 
+// ComputeID serializes the event into a pooled buffer and returns its hash, without allocating.
+func (evt *Event) ComputeID() [32]byte {
+	bufp := serializationPool.Get().(*[]byte)
+	buf := evt.SerializeTo((*bufp)[:0])
+	h := sha256.Sum256(buf)
+
+	// don't keep huge buffers around forever
+	if cap(buf) <= maxPooledSerializationSize {
+		*bufp = buf
+		serializationPool.Put(bufp)
+	}
+
+	return h
+}
+
 // CheckID checks if the implied ID matches the given ID
 func (evt *Event) CheckID() bool {
-	ser := evt.Serialize()
-	h := sha256.Sum256(ser)
+	if len(evt.ID) != 64 {
+		return false
+	}
+
+	h := evt.ComputeID()
 
 	const hextable = "0123456789abcdef"
 
@@ -55,19 +78,25 @@ func (evt *Event) CheckID() bool {
 // Serialize outputs a byte array that can be hashed/signed to identify/authenticate.
 // JSON encoding as defined in RFC4627.
 func (evt *Event) Serialize() []byte {
+	return evt.SerializeTo(make([]byte, 0, evt.serializedSizeHint()))
+}
+
+// SerializeTo is like Serialize, but appends to dst and returns the extended buffer.
+// If dst has enough capacity nothing is allocated.
+func (evt *Event) SerializeTo(dst []byte) []byte {
 	// the serialization process is just putting everything into a JSON array
 	// so the order is kept. See NIP-01
-	dst := make([]byte, 0)
+	dst = slices.Grow(dst, evt.serializedSizeHint())
 
 	// the header portion is easy to serialize
 	// [0,"pubkey",created_at,kind,[
-	dst = append(dst, []byte(
-		fmt.Sprintf(
-			"[0,\"%s\",%d,%d,",
-			evt.PubKey,
-			evt.CreatedAt,
-			evt.Kind,
-		))...)
+	dst = append(dst, `[0,"`...)
+	dst = append(dst, evt.PubKey...)
+	dst = append(dst, `",`...)
+	dst = strconv.AppendInt(dst, int64(evt.CreatedAt), 10)
+	dst = append(dst, ',')
+	dst = strconv.AppendInt(dst, int64(evt.Kind), 10)
+	dst = append(dst, ',')
 
 	// tags
 	dst = evt.Tags.marshalTo(dst)
@@ -79,3 +108,25 @@ func (evt *Event) Serialize() []byte {
 
 	return dst
 }
+
+// serializedSizeHint is the size of the serialized event assuming nothing needs escaping.
+func (evt *Event) serializedSizeHint() int {
+	// [0,"<64>",<20>,<5>,[...],"..."]
+	size := 4 + len(evt.PubKey) + 2 + 20 + 1 + 5 + 1 + 2 + 1 + 2 + len(evt.Content) + 1
+	for _, tag := range evt.Tags {
+		size += 3 // [], plus the comma
+		for _, s := range tag {
+			size += len(s) + 3 // "", plus the comma
+		}
+	}
+	return size
+}
+
+const maxPooledSerializationSize = 64 * 1024
+
+var serializationPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
@@ -0,0 +1,238 @@
+package nostr
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BinaryVersion is the first byte of every binary-encoded event.
+const BinaryVersion byte = 1
+
+// MaxBinaryEventSize is the largest record BinaryReader will accept.
+var MaxBinaryEventSize = 16 << 20
+
+var (
+	ErrBinaryUnknownVersion = errors.New("unknown binary event version")
+	ErrBinaryTruncated      = errors.New("binary event is truncated")
+	ErrBinaryTooLarge       = errors.New("binary event is too large")
+	ErrBinaryInvalidVarint  = errors.New("binary event has an invalid varint")
+	ErrBinaryNonCanonical   = errors.New("id, pubkey and sig must be lowercase hex of the right size")
+)
+
+// MarshalBinary encodes the event in a compact binary form: raw id, pubkey and signature, varint timestamp
+// and kind, then length-prefixed tags and content. id, pubkey and sig must be valid lowercase hex, so
+// decoding gives back exactly the same event.
+func (evt Event) MarshalBinary() ([]byte, error) {
+	return evt.AppendBinary(make([]byte, 0, evt.binarySizeHint()))
+}
+
+// AppendBinary is like MarshalBinary, but appends to dst.
+func (evt Event) AppendBinary(dst []byte) ([]byte, error) {
+	if len(evt.ID) != 64 || len(evt.PubKey) != 64 || len(evt.Sig) != 128 ||
+		!isLowerHex(evt.ID) || !isLowerHex(evt.PubKey) || !isLowerHex(evt.Sig) {
+		return dst, ErrBinaryNonCanonical
+	}
+
+	id, _ := decode32ByteHex(evt.ID)
+	pk, _ := decode32ByteHex(evt.PubKey)
+	r, _ := decode32ByteHex(evt.Sig[0:64])
+	s, _ := decode32ByteHex(evt.Sig[64:128])
+
+	dst = append(dst, BinaryVersion)
+	dst = append(dst, id[:]...)
+	dst = append(dst, pk[:]...)
+	dst = append(dst, r[:]...)
+	dst = append(dst, s[:]...)
+	dst = binary.AppendVarint(dst, int64(evt.CreatedAt))
+	dst = binary.AppendVarint(dst, int64(evt.Kind))
+
+	dst = binary.AppendUvarint(dst, uint64(len(evt.Tags)))
+	for _, tag := range evt.Tags {
+		dst = binary.AppendUvarint(dst, uint64(len(tag)))
+		for _, item := range tag {
+			dst = binary.AppendUvarint(dst, uint64(len(item)))
+			dst = append(dst, item...)
+		}
+	}
+
+	dst = binary.AppendUvarint(dst, uint64(len(evt.Content)))
+	dst = append(dst, evt.Content...)
+
+	return dst, nil
+}
+
+// UnmarshalBinary decodes an event encoded with MarshalBinary.
+func (evt *Event) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return ErrBinaryTruncated
+	}
+	if data[0] != BinaryVersion {
+		return fmt.Errorf("%w: %d", ErrBinaryUnknownVersion, data[0])
+	}
+	if len(data) < 1+32+32+64 {
+		return ErrBinaryTruncated
+	}
+
+	// a single string for id, pubkey and sig
+	hexes := hex.EncodeToString(data[1 : 1+32+32+64])
+	evt.ID = hexes[0:64]
+	evt.PubKey = hexes[64:128]
+	evt.Sig = hexes[128:256]
+
+	// and another for all tags and the content
+	d := binaryDecoder{data: string(data[1+32+32+64:])}
+
+	evt.CreatedAt = Timestamp(d.varint())
+	evt.Kind = int(d.varint())
+
+	ntags := d.length()
+	evt.Tags = make(Tags, ntags)
+	for i := range evt.Tags {
+		nitems := d.length()
+		tag := make(Tag, nitems)
+		for j := range tag {
+			tag[j] = d.string()
+		}
+		evt.Tags[i] = tag
+	}
+
+	evt.Content = d.string()
+
+	if d.err != nil {
+		return d.err
+	}
+	if d.pos != len(d.data) {
+		return fmt.Errorf("%d unexpected bytes after binary event", len(d.data)-d.pos)
+	}
+	return nil
+}
+
+func (evt Event) binarySizeHint() int {
+	size := 1 + 32 + 32 + 64 + 10 + 3 + 2 + len(evt.Content) + 3
+	for _, tag := range evt.Tags {
+		size++
+		for _, item := range tag {
+			size += len(item) + 2
+		}
+	}
+	return size
+}
+
+type binaryDecoder struct {
+	data string
+	pos  int
+	err  error
+}
+
+func (d *binaryDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var v uint64
+	for shift := 0; shift < 64; shift += 7 {
+		if d.pos >= len(d.data) {
+			d.err = ErrBinaryTruncated
+			return 0
+		}
+		b := d.data[d.pos]
+		d.pos++
+		if (shift == 63 && b > 1) || (shift > 0 && b == 0) {
+			// overflow or a non-minimal encoding, which would make the same event have two encodings
+			break
+		}
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v
+		}
+	}
+	d.err = ErrBinaryInvalidVarint
+	return 0
+}
+
+func (d *binaryDecoder) varint() int64 {
+	u := d.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+// length reads a length and makes sure there are at least as many bytes remaining,
+// so corrupted data can't make us allocate huge amounts of memory.
+func (d *binaryDecoder) length() int {
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.data)-d.pos) {
+		d.err = ErrBinaryTruncated
+	}
+	if d.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+func (d *binaryDecoder) string() string {
+	n := d.length()
+	s := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return s
+}
+
+// BinaryWriter writes a stream of binary-encoded events, each prefixed by its length.
+type BinaryWriter struct {
+	w      io.Writer
+	record []byte
+	buf    []byte
+}
+
+func NewBinaryWriter(w io.Writer) *BinaryWriter {
+	return &BinaryWriter{w: w}
+}
+
+// WriteEvent encodes and writes a single event.
+func (bw *BinaryWriter) WriteEvent(evt *Event) error {
+	var err error
+	bw.record, err = evt.AppendBinary(bw.record[:0])
+	if err != nil {
+		return err
+	}
+
+	bw.buf = binary.AppendUvarint(bw.buf[:0], uint64(len(bw.record)))
+	bw.buf = append(bw.buf, bw.record...)
+	_, err = bw.w.Write(bw.buf)
+	return err
+}
+
+// BinaryReader reads a stream of events written by BinaryWriter.
+type BinaryReader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func NewBinaryReader(r io.Reader) *BinaryReader {
+	return &BinaryReader{r: bufio.NewReader(r)}
+}
+
+// ReadEvent reads the next event into evt. It returns io.EOF when the stream ends cleanly.
+func (br *BinaryReader) ReadEvent(evt *Event) error {
+	size, err := binary.ReadUvarint(br.r)
+	if err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		return ErrBinaryTruncated
+	}
+	if size > uint64(MaxBinaryEventSize) {
+		return ErrBinaryTooLarge
+	}
+
+	if cap(br.buf) < int(size) {
+		br.buf = make([]byte, size)
+	}
+	br.buf = br.buf[:size]
+	if _, err := io.ReadFull(br.r, br.buf); err != nil {
+		return ErrBinaryTruncated
+	}
+
+	return evt.UnmarshalBinary(br.buf)
+}
@@ -0,0 +1,116 @@
+package nostr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBinary(t *testing.T) {
+	t.Parallel()
+
+	evt := Event{
+		Kind:      30023,
+		CreatedAt: 1700000000,
+		Tags:      Tags{{"d", "article"}, {"t", "nostr"}, {"e", "92570b321da503eac8014b23447301eb3d0bbdfbace0d11a4e4072e72bb7205d", "", "root"}},
+		Content:   "hello\n\"world\" ✨",
+	}
+	require.NoError(t, evt.Sign(GeneratePrivateKey()))
+
+	b, err := evt.MarshalBinary()
+	require.NoError(t, err)
+	require.Less(t, len(b), len(evt.String()))
+
+	var back Event
+	require.NoError(t, back.UnmarshalBinary(b))
+	require.Equal(t, evt, back)
+	require.True(t, back.CheckID())
+
+	// trailing garbage, truncation and unknown versions are rejected
+	require.Error(t, back.UnmarshalBinary(append(b, 0)))
+	require.ErrorIs(t, back.UnmarshalBinary(b[:len(b)-1]), ErrBinaryTruncated)
+	require.ErrorIs(t, back.UnmarshalBinary(append([]byte{2}, b[1:]...)), ErrBinaryUnknownVersion)
+
+	// we can't encode events that wouldn't come back the same
+	upper := evt
+	upper.ID = "92570B321DA503EAC8014B23447301EB3D0BBDFBACE0D11A4E4072E72BB7205D"
+	_, err = upper.MarshalBinary()
+	require.ErrorIs(t, err, ErrBinaryNonCanonical)
+}
+
+func TestBinaryStream(t *testing.T) {
+	t.Parallel()
+
+	events := makeBatch(t, 50)
+	events[7].Content = string(bytes.Repeat([]byte("x"), 100000))
+	events[7].Sign(GeneratePrivateKey())
+
+	buf := &bytes.Buffer{}
+	w := NewBinaryWriter(buf)
+	for _, evt := range events {
+		require.NoError(t, w.WriteEvent(evt))
+	}
+
+	r := NewBinaryReader(bytes.NewReader(buf.Bytes()))
+	for _, evt := range events {
+		var back Event
+		require.NoError(t, r.ReadEvent(&back))
+		require.Equal(t, *evt, back)
+	}
+	require.Equal(t, io.EOF, r.ReadEvent(&Event{}))
+
+	r = NewBinaryReader(bytes.NewReader(buf.Bytes()[:buf.Len()-3]))
+	var err error
+	for err == nil {
+		err = r.ReadEvent(&Event{})
+	}
+	require.ErrorIs(t, err, ErrBinaryTruncated)
+}
+
+func FuzzEventBinary(f *testing.F) {
+	f.Add(int64(1700000000), 1, "t", "nostr", "hello", []byte{1, 2, 3})
+	f.Add(int64(-1), -5, "", "\x00\"\\", "\xff\xfe", []byte{})
+	f.Fuzz(func(t *testing.T, createdAt int64, kind int, tagName string, tagValue string, content string, keyMaterial []byte) {
+		evt := Event{
+			CreatedAt: Timestamp(createdAt),
+			Kind:      kind,
+			Tags:      Tags{{tagName, tagValue}, {}, {tagValue}},
+			Content:   content,
+		}
+		key := make([]byte, 96)
+		copy(key, keyMaterial)
+		evt.PubKey = hex.EncodeToString(key[0:32])
+		evt.Sig = hex.EncodeToString(key[32:96])
+		evt.ID = evt.GetID()
+
+		b, err := evt.MarshalBinary()
+		require.NoError(t, err)
+
+		var back Event
+		require.NoError(t, back.UnmarshalBinary(b))
+		require.Equal(t, evt, back)
+		require.Equal(t, evt.ID, back.GetID())
+		require.Equal(t, evt.String(), back.String())
+	})
+}
+
+func FuzzEventBinaryDecode(f *testing.F) {
+	evt := Event{Kind: 1, CreatedAt: 1700000000, Tags: Tags{{"t", "x"}}, Content: "hello"}
+	evt.Sign(GeneratePrivateKey())
+	b, _ := evt.MarshalBinary()
+	f.Add(b)
+	f.Add([]byte{1})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var evt Event
+		if err := evt.UnmarshalBinary(data); err != nil {
+			return
+		}
+		// anything we decode must encode back to the exact same bytes
+		again, err := evt.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, data, again)
+	})
+}
@@ -84,6 +84,8 @@ func easyjsonF642ad3eDecodeGithubComNbdWtfGoNostr(in *jlexer.Lexer, out *Event)
 			out.Content = in.String()
 		case "sig":
 			out.Sig = in.String()
+		default:
+			in.SkipRecursive()
 		}
 		in.WantComma()
 	}
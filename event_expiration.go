@@ -0,0 +1,28 @@
+package nostr
+
+import "strconv"
+
+// Expiration returns the NIP-40 expiration of the event, if it has a valid one.
+func (evt *Event) Expiration() (Timestamp, bool) {
+	tag := evt.Tags.GetFirst([]string{"expiration", ""})
+	if tag == nil {
+		return 0, false
+	}
+	exp, err := strconv.ParseInt((*tag)[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return Timestamp(exp), true
+}
+
+// SetExpiration replaces the "expiration" tag of the event, or adds one. It must be called before signing.
+func (evt *Event) SetExpiration(exp Timestamp) {
+	evt.Tags = evt.Tags.FilterOut([]string{"expiration", ""})
+	evt.Tags = append(evt.Tags, Tag{"expiration", strconv.FormatInt(int64(exp), 10)})
+}
+
+// IsExpired tells if the event has an expiration that is already past.
+func (evt *Event) IsExpired() bool {
+	exp, ok := evt.Expiration()
+	return ok && exp <= Now()
+}
@@ -0,0 +1,31 @@
+package nostr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventExpiration(t *testing.T) {
+	evt := Event{Kind: KindTextNote, Tags: Tags{{"t", "x"}}}
+	_, ok := evt.Expiration()
+	require.False(t, ok)
+	require.False(t, evt.IsExpired())
+
+	evt.SetExpiration(Now() + 60)
+	exp, ok := evt.Expiration()
+	require.True(t, ok)
+	require.Equal(t, Now()+60, exp)
+	require.False(t, evt.IsExpired())
+
+	// replaces the existing tag
+	evt.SetExpiration(Now() - 1)
+	require.Len(t, evt.Tags, 2)
+	require.Equal(t, "t", evt.Tags[0][0])
+	require.True(t, evt.IsExpired())
+
+	evt.Tags = Tags{{"expiration", "soon"}}
+	_, ok = evt.Expiration()
+	require.False(t, ok)
+	require.False(t, evt.IsExpired())
+}
@@ -0,0 +1,32 @@
+package nostr
+
+import (
+	stdjson "encoding/json"
+)
+
+type rawJSON struct {
+	data []byte
+
+	// the id and sig the event had when it was decoded, so we can tell if it was changed
+	id  string
+	sig string
+}
+
+// UnmarshalJSONWithRaw is like UnmarshalJSON, but also keeps data on the event, including any unknown fields,
+// so it can be sent or stored again exactly as it was received. data must not be modified afterwards.
+func (evt *Event) UnmarshalJSONWithRaw(data []byte) error {
+	if err := evt.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	evt.raw = &rawJSON{data: data, id: evt.ID, sig: evt.Sig}
+	return nil
+}
+
+// RawJSON returns the original JSON of an event decoded with UnmarshalJSONWithRaw (or received from a Relay
+// created with WithRawEvents()), as long as the event wasn't modified since then.
+func (evt *Event) RawJSON() (stdjson.RawMessage, bool) {
+	if evt.raw == nil || evt.raw.id != evt.ID || evt.raw.sig != evt.Sig || !evt.CheckID() {
+		return nil, false
+	}
+	return evt.raw.data, true
+}
@@ -0,0 +1,102 @@
+package nostr
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func makeRawEvent(t *testing.T) (*Event, string) {
+	evt := &Event{Kind: 1, CreatedAt: 1700000000, Tags: Tags{{"t", "raw"}}, Content: "<hello>"}
+	require.NoError(t, evt.Sign(GeneratePrivateKey()))
+
+	// different field order, spacing, escaping and an unknown field
+	raw := fmt.Sprintf(`{ "id":"%s","sig":"%s", "pubkey":"%s","created_at":%d,"kind":1,"tags":[["t","raw"]],"content":"<hello>","extra":{"x":[1,2]} }`,
+		evt.ID, evt.Sig, evt.PubKey, evt.CreatedAt)
+	return evt, raw
+}
+
+func TestEventRawJSON(t *testing.T) {
+	t.Parallel()
+
+	orig, raw := makeRawEvent(t)
+
+	var plain Event
+	require.NoError(t, plain.UnmarshalJSON([]byte(raw)))
+	_, ok := plain.RawJSON()
+	require.False(t, ok)
+
+	var evt Event
+	require.NoError(t, evt.UnmarshalJSONWithRaw([]byte(raw)))
+	require.Equal(t, orig.ID, evt.ID)
+	data, ok := evt.RawJSON()
+	require.True(t, ok)
+	require.Equal(t, raw, string(data))
+
+	// envelopes send the raw bytes
+	env, _ := EventEnvelope{Events: []*Event{&evt, orig}}.MarshalJSON()
+	require.Equal(t, `["EVENT",`+raw+`,`+orig.String()+`]`, string(env))
+
+	// but not after the event was modified
+	evt.Content = "changed"
+	_, ok = evt.RawJSON()
+	require.False(t, ok)
+	evt.Content = orig.Content
+	evt.Sig = orig.Sig[64:] + orig.Sig[:64]
+	_, ok = evt.RawJSON()
+	require.False(t, ok)
+
+	// parsing messages
+	msg := []byte(`["EVENT","sub",` + raw + `]`)
+	parsed, err := parseMessage(msg, true)
+	require.NoError(t, err)
+	data, ok = parsed.(*EventEnvelope).Events[0].RawJSON()
+	require.True(t, ok)
+	require.Equal(t, raw, string(data))
+
+	parsed, err = ParseMessage(msg)
+	require.NoError(t, err)
+	_, ok = parsed.(*EventEnvelope).Events[0].RawJSON()
+	require.False(t, ok)
+}
+
+func TestRelayRawEvents(t *testing.T) {
+	_, raw := makeRawEvent(t)
+
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		var req []stdjson.RawMessage
+		if err := websocket.JSON.Receive(conn, &req); err != nil {
+			return
+		}
+		var id string
+		json.Unmarshal(req[1], &id)
+		websocket.Message.Send(conn, `["EVENT","`+id+`",`+raw+`]`)
+		websocket.Message.Send(conn, `["EOSE","`+id+`"]`)
+		conn.Read(make([]byte, 1))
+	})
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	relay, err := RelayConnect(ctx, ws.URL, WithRawEvents())
+	require.NoError(t, err)
+	defer relay.Close()
+
+	sub, err := relay.Subscribe(ctx, Filters{{Kinds: []int{1}}})
+	require.NoError(t, err)
+
+	select {
+	case evt := <-sub.Events:
+		data, ok := evt.RawJSON()
+		require.True(t, ok)
+		require.Equal(t, raw, string(data))
+	case <-ctx.Done():
+		t.Fatal("no event received")
+	}
+}
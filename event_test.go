@@ -113,3 +113,61 @@ func BenchmarkIDCheck(b *testing.B) {
 		}
 	})
 }
+
+func TestSerializationAllocations(t *testing.T) {
+	evt := Event{
+		Kind:      1,
+		CreatedAt: Timestamp(1700000000),
+		Tags:      Tags{{"e", "92570b321da503eac8014b23447301eb3d0bbdfbace0d11a4e4072e72bb7205d", "", "root"}, {"t", "nostr"}},
+		Content:   "hello \"world\"\n",
+	}
+	evt.Sign(GeneratePrivateKey())
+
+	buf := make([]byte, 0, 1024)
+	require.Equal(t, string(evt.Serialize()), string(evt.SerializeTo(buf)))
+	require.Equal(t, "prefix"+string(evt.Serialize()), string(evt.SerializeTo([]byte("prefix"))))
+
+	require.Zero(t, testing.AllocsPerRun(100, func() { buf = evt.SerializeTo(buf[:0]) }))
+	require.Zero(t, testing.AllocsPerRun(100, func() { evt.ComputeID() }))
+	require.Zero(t, testing.AllocsPerRun(100, func() { evt.CheckID() }))
+	require.Equal(t, float64(1), testing.AllocsPerRun(100, func() { evt.Serialize() }))
+}
+
+func BenchmarkSerialization(b *testing.B) {
+	evt := Event{
+		Kind:      1,
+		CreatedAt: Timestamp(1700000000),
+		Tags:      Tags{{"e", "92570b321da503eac8014b23447301eb3d0bbdfbace0d11a4e4072e72bb7205d", "", "root"}, {"t", "nostr"}},
+		Content:   "hello, this is a somewhat normal note with an \"escaped\" part\nand a second line",
+	}
+	evt.Sign(GeneratePrivateKey())
+
+	b.Run("Serialize", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			evt.Serialize()
+		}
+	})
+
+	b.Run("SerializeTo", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := make([]byte, 0, 1024)
+		for i := 0; i < b.N; i++ {
+			buf = evt.SerializeTo(buf[:0])
+		}
+	})
+
+	b.Run("ComputeID", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			evt.ComputeID()
+		}
+	})
+
+	b.Run("GetID", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			evt.GetID()
+		}
+	})
+}
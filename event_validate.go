@@ -0,0 +1,144 @@
+package nostr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEventInvalidID        = errors.New("must be 64 characters of lowercase hex")
+	ErrEventIDMismatch       = errors.New("does not match the hash of the event")
+	ErrEventInvalidPubKey    = errors.New("must be a valid 32-byte public key in lowercase hex")
+	ErrEventInvalidSig       = errors.New("must be 128 characters of lowercase hex")
+	ErrEventBadSignature     = errors.New("signature verification failed")
+	ErrEventEmptyTag         = errors.New("tag must not be empty")
+	ErrEventInvalidKind      = errors.New("must be between 0 and 65535")
+	ErrEventNegativeTime     = errors.New("must not be negative")
+	ErrEventTooFarInFuture   = errors.New("is too far in the future")
+	ErrEventTooOld           = errors.New("is too old")
+	ErrEventSignatureFailure = errors.New("signature could not be checked")
+)
+
+// ValidateOptions control the checks done by Event.Validate. The zero value does all the checks
+// that don't depend on the current time.
+type ValidateOptions struct {
+	// SkipSignature skips the (expensive) signature verification, formats are still checked.
+	SkipSignature bool
+
+	// MaxFutureDrift, if not zero, rejects events with created_at further than this in the future.
+	MaxFutureDrift time.Duration
+
+	// MaxAge, if not zero, rejects events with created_at older than this.
+	MaxAge time.Duration
+
+	// Now is used as the current time for MaxFutureDrift and MaxAge, if zero it defaults to Now().
+	Now Timestamp
+}
+
+// EventError is returned by Event.Validate for each problem found, use errors.Is() with
+// the ErrEvent* values to check what is wrong.
+type EventError struct {
+	// Field is the JSON name of the offending field, like "id", "sig" or "tags".
+	Field string
+	// Index is the position of the offending item inside Field, or -1.
+	Index int
+	Err   error
+}
+
+func (e EventError) Error() string {
+	return "invalid event " + e.describe()
+}
+
+func (e EventError) Unwrap() error { return e.Err }
+
+func (e EventError) describe() string {
+	if e.Index >= 0 {
+		return fmt.Sprintf("%s[%d]: %s", e.Field, e.Index, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Err)
+}
+
+// Validate checks everything about the event and returns all the problems found joined together
+// as EventError values, or nil.
+//
+// Tags can't contain anything other than strings since they are decoded into Tags, so only their
+// structure is checked here.
+func (evt *Event) Validate(opts ValidateOptions) error {
+	var errs []error
+
+	if !IsValid32ByteHex(evt.ID) {
+		errs = append(errs, EventError{"id", -1, ErrEventInvalidID})
+	} else if !evt.CheckID() {
+		errs = append(errs, EventError{"id", -1, ErrEventIDMismatch})
+	}
+
+	validPubKey := IsValid32ByteHex(evt.PubKey) && IsValidPublicKey(evt.PubKey)
+	if !validPubKey {
+		errs = append(errs, EventError{"pubkey", -1, ErrEventInvalidPubKey})
+	}
+
+	validSig := len(evt.Sig) == 128 && isLowerHex(evt.Sig)
+	if !validSig {
+		errs = append(errs, EventError{"sig", -1, ErrEventInvalidSig})
+	}
+
+	if evt.Kind < 0 || evt.Kind > 65535 {
+		errs = append(errs, EventError{"kind", -1, ErrEventInvalidKind})
+	}
+
+	if evt.CreatedAt < 0 {
+		errs = append(errs, EventError{"created_at", -1, ErrEventNegativeTime})
+	} else if opts.MaxFutureDrift != 0 || opts.MaxAge != 0 {
+		now := opts.Now
+		if now == 0 {
+			now = Now()
+		}
+		if opts.MaxFutureDrift != 0 && evt.CreatedAt > now+Timestamp(opts.MaxFutureDrift/time.Second) {
+			errs = append(errs, EventError{"created_at", -1, ErrEventTooFarInFuture})
+		}
+		if opts.MaxAge != 0 && evt.CreatedAt < now-Timestamp(opts.MaxAge/time.Second) {
+			errs = append(errs, EventError{"created_at", -1, ErrEventTooOld})
+		}
+	}
+
+	for i, tag := range evt.Tags {
+		if len(tag) == 0 {
+			errs = append(errs, EventError{"tags", i, ErrEventEmptyTag})
+		}
+	}
+
+	if !opts.SkipSignature && validPubKey && validSig {
+		if ok, err := evt.CheckSignature(); err != nil {
+			errs = append(errs, EventError{"sig", -1, fmt.Errorf("%w: %w", ErrEventSignatureFailure, err)})
+		} else if !ok {
+			errs = append(errs, EventError{"sig", -1, ErrEventBadSignature})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// InvalidReason turns an error returned by Event.Validate into a message suitable for an OK
+// envelope sent by a relay, with the "invalid: " prefix.
+func InvalidReason(err error) string {
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	} else {
+		errs = []error{err}
+	}
+
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		var eerr EventError
+		if errors.As(err, &eerr) {
+			msgs[i] = eerr.describe()
+		} else {
+			msgs[i] = err.Error()
+		}
+	}
+
+	return "invalid: " + strings.Join(msgs, "; ")
+}
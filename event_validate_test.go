@@ -0,0 +1,56 @@
+package nostr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventValidate(t *testing.T) {
+	t.Parallel()
+
+	sk := GeneratePrivateKey()
+	evt := Event{Kind: 1, CreatedAt: 1700000000, Tags: Tags{{"t", "test"}}, Content: "hello"}
+	require.NoError(t, evt.Sign(sk))
+	require.NoError(t, evt.Validate(ValidateOptions{}))
+
+	// time bounds
+	opts := ValidateOptions{MaxFutureDrift: 15 * time.Minute, MaxAge: time.Hour, Now: 1700000000}
+	require.NoError(t, evt.Validate(opts))
+	opts.Now = evt.CreatedAt - 3600
+	require.ErrorIs(t, evt.Validate(opts), ErrEventTooFarInFuture)
+	opts.Now = evt.CreatedAt + 7200
+	require.ErrorIs(t, evt.Validate(opts), ErrEventTooOld)
+
+	// content changed after signing
+	tampered := evt
+	tampered.Content = "bye"
+	err := tampered.Validate(ValidateOptions{})
+	require.ErrorIs(t, err, ErrEventIDMismatch)
+	require.ErrorIs(t, err, ErrEventBadSignature)
+	err = tampered.Validate(ValidateOptions{SkipSignature: true})
+	require.ErrorIs(t, err, ErrEventIDMismatch)
+	require.NotErrorIs(t, err, ErrEventBadSignature)
+
+	// broken everything
+	broken := Event{ID: "abc", PubKey: strings.Repeat("z", 64), Sig: "00", Kind: 70000, CreatedAt: -1, Tags: Tags{{"p"}, {}}}
+	require.False(t, broken.CheckID())
+	err = broken.Validate(ValidateOptions{})
+	require.ErrorIs(t, err, ErrEventInvalidID)
+	require.ErrorIs(t, err, ErrEventInvalidPubKey)
+	require.ErrorIs(t, err, ErrEventInvalidSig)
+	require.ErrorIs(t, err, ErrEventInvalidKind)
+	require.ErrorIs(t, err, ErrEventNegativeTime)
+	require.ErrorIs(t, err, ErrEventEmptyTag)
+
+	var eerr EventError
+	require.True(t, errors.As(err, &eerr))
+	require.Equal(t, "id", eerr.Field)
+
+	require.Equal(t,
+		"invalid: id: must be 64 characters of lowercase hex; pubkey: must be a valid 32-byte public key in lowercase hex; sig: must be 128 characters of lowercase hex; kind: must be between 0 and 65535; created_at: must not be negative; tags[1]: tag must not be empty",
+		InvalidReason(err))
+}
@@ -0,0 +1,342 @@
+package nostr
+
+import (
+	"slices"
+)
+
+// Contains reports whether every event matched by other is also matched by ef. It is conservative:
+// when it returns true that is guaranteed, but it may return false for some filters that are actually
+// contained, like when other is only satisfiable in ways ef happens to cover.
+//
+// Limits are taken into account as in NIP-01: a filter with a limit only contains filters with the same
+// conditions and a smaller or equal limit, since the newest N events of a subset may not be among the
+// newest N events of the whole set.
+func (ef Filter) Contains(other Filter) bool {
+	if ef.LimitZero || ef.Limit > 0 {
+		if other.LimitZero != ef.LimitZero || (ef.Limit > 0 && (other.Limit <= 0 || other.Limit > ef.Limit)) {
+			return false
+		}
+		a, b := ef, other
+		a.Limit, a.LimitZero, b.Limit, b.LimitZero = 0, false, 0, false
+		return a.Key() == b.Key()
+	}
+
+	if other.isEmpty() {
+		return true
+	}
+
+	if !containsList(ef.IDs, other.IDs) || !containsList(ef.Kinds, other.Kinds) || !containsList(ef.Authors, other.Authors) {
+		return false
+	}
+
+	if ef.Since != nil && (other.Since == nil || *other.Since < *ef.Since) {
+		return false
+	}
+	if ef.Until != nil && (other.Until == nil || *other.Until > *ef.Until) {
+		return false
+	}
+
+	if ef.Search != "" && ef.Search != other.Search {
+		return false
+	}
+
+	for name, sets := range ef.Tags {
+		otherSets, ok := other.Tags[name]
+		if !ok {
+			return false
+		}
+		if matchesAnyTag(sets) {
+			continue
+		}
+		if matchesAnyTag(otherSets) {
+			return false
+		}
+		for _, q := range otherSets {
+			if !slices.ContainsFunc(sets, func(p TagValues) bool { return tagPatternSubsumes(p, q) }) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Intersect returns a filter that matches exactly the events matched by both ef and other.
+// It returns false if no event can match both or if the intersection can't be expressed as a
+// single filter (i.e. both have different "search" terms).
+//
+// Limits are dropped from the result since the intersection of two limited result sets can't be
+// expressed as a filter.
+func (ef Filter) Intersect(other Filter) (Filter, bool) {
+	res := Filter{
+		IDs:     intersectList(ef.IDs, other.IDs),
+		Kinds:   intersectList(ef.Kinds, other.Kinds),
+		Authors: intersectList(ef.Authors, other.Authors),
+		Since:   ef.Since,
+		Until:   ef.Until,
+		Search:  ef.Search,
+	}
+
+	if other.Since != nil && (res.Since == nil || *other.Since > *res.Since) {
+		res.Since = other.Since
+	}
+	if other.Until != nil && (res.Until == nil || *other.Until < *res.Until) {
+		res.Until = other.Until
+	}
+	if res.Since != nil {
+		since := *res.Since
+		res.Since = &since
+	}
+	if res.Until != nil {
+		until := *res.Until
+		res.Until = &until
+	}
+
+	if other.Search != "" {
+		if res.Search != "" && res.Search != other.Search {
+			return Filter{}, false
+		}
+		res.Search = other.Search
+	}
+
+	if len(ef.Tags) > 0 || len(other.Tags) > 0 {
+		res.Tags = make(TagMap, len(ef.Tags)+len(other.Tags))
+	}
+	for name, sets := range ef.Tags {
+		res.Tags[name] = slices.Clone(sets)
+	}
+	for name, otherSets := range other.Tags {
+		sets, ok := res.Tags[name]
+		if !ok || matchesAnyTag(sets) {
+			res.Tags[name] = slices.Clone(otherSets)
+			continue
+		}
+		if matchesAnyTag(otherSets) {
+			continue
+		}
+
+		unified := make([]TagValues, 0, len(sets)*len(otherSets))
+		for _, p := range sets {
+			for _, q := range otherSets {
+				if u, ok := unifyTagPatterns(p, q); ok {
+					unified = append(unified, u)
+				}
+			}
+		}
+		if len(unified) == 0 {
+			return Filter{}, false
+		}
+		res.Tags[name] = unified
+	}
+
+	if res.isEmpty() {
+		return Filter{}, false
+	}
+
+	return res, true
+}
+
+// Merge returns an equivalent list of filters in which filters that differ in a single dimension
+// (ids, kinds, authors, time bounds or one tag) are merged into one, and filters that are contained
+// in others are removed. Filters with limits are only merged with identical filters.
+func (eff Filters) Merge() Filters {
+	res := make(Filters, 0, len(eff))
+	for _, f := range eff {
+		res = append(res, f.Canonicalize())
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+	outer:
+		for i := 0; i < len(res); i++ {
+			for j := i + 1; j < len(res); j++ {
+				if merged, ok := mergeFilters(res[i], res[j]); ok {
+					res[i] = merged
+					res = slices.Delete(res, j, j+1)
+					changed = true
+					break outer
+				}
+			}
+		}
+	}
+
+	return res
+}
+
+// mergeFilters tries to merge two canonical filters without changing what is matched.
+func mergeFilters(a, b Filter) (Filter, bool) {
+	if a.Key() == b.Key() {
+		return a, true
+	}
+
+	if a.Limit > 0 || a.LimitZero || b.Limit > 0 || b.LimitZero {
+		return Filter{}, false
+	}
+
+	if a.Contains(b) {
+		return a, true
+	}
+	if b.Contains(a) {
+		return b, true
+	}
+
+	sameExcept := func(clear func(f *Filter)) bool {
+		ac, bc := a.Clone(), b.Clone()
+		clear(&ac)
+		clear(&bc)
+		return ac.Key() == bc.Key()
+	}
+
+	merged := a.Clone()
+	switch {
+	case sameExcept(func(f *Filter) { f.IDs = nil }):
+		merged.IDs = unionList(a.IDs, b.IDs)
+	case sameExcept(func(f *Filter) { f.Kinds = nil }):
+		merged.Kinds = unionList(a.Kinds, b.Kinds)
+	case sameExcept(func(f *Filter) { f.Authors = nil }):
+		merged.Authors = unionList(a.Authors, b.Authors)
+	case sameExcept(func(f *Filter) { f.Since, f.Until = nil, nil }):
+		// only contiguous time ranges can be merged
+		if a.Until != nil && b.Since != nil && *a.Until+1 < *b.Since {
+			return Filter{}, false
+		}
+		if b.Until != nil && a.Since != nil && *b.Until+1 < *a.Since {
+			return Filter{}, false
+		}
+		merged.Since, merged.Until = nil, nil
+		if a.Since != nil && b.Since != nil {
+			since := min(*a.Since, *b.Since)
+			merged.Since = &since
+		}
+		if a.Until != nil && b.Until != nil {
+			until := max(*a.Until, *b.Until)
+			merged.Until = &until
+		}
+	default:
+		names := make([]string, 0, len(a.Tags)+len(b.Tags))
+		for name := range a.Tags {
+			names = append(names, name)
+		}
+		for name := range b.Tags {
+			names = append(names, name)
+		}
+		for _, name := range names {
+			if !sameExcept(func(f *Filter) { delete(f.Tags, name) }) {
+				continue
+			}
+			as, aok := a.Tags[name]
+			bs, bok := b.Tags[name]
+			if !aok || !bok {
+				// one of them doesn't care about this tag at all
+				delete(merged.Tags, name)
+			} else if matchesAnyTag(as) || matchesAnyTag(bs) {
+				merged.Tags[name] = []TagValues{}
+			} else {
+				merged.Tags[name] = append(slices.Clone(as), bs...)
+			}
+			return merged.Canonicalize(), true
+		}
+		return Filter{}, false
+	}
+
+	return merged.Canonicalize(), true
+}
+
+// isEmpty tells if the filter can't possibly match any event.
+func (ef Filter) isEmpty() bool {
+	if (ef.IDs != nil && len(ef.IDs) == 0) ||
+		(ef.Kinds != nil && len(ef.Kinds) == 0) ||
+		(ef.Authors != nil && len(ef.Authors) == 0) {
+		return true
+	}
+	if ef.Since != nil && ef.Until != nil && *ef.Since > *ef.Until {
+		return true
+	}
+	return false
+}
+
+// matchesAnyTag tells if a list of tag value sets would match any tag with the given name.
+func matchesAnyTag(sets []TagValues) bool {
+	return len(sets) == 0 || slices.ContainsFunc(sets, TagValues.Empty)
+}
+
+// tagPatternSubsumes tells if every tag matched by q is also matched by p.
+func tagPatternSubsumes(p, q TagValues) bool {
+	for i, v := range p {
+		if v == nil {
+			continue
+		}
+		if i >= len(q) || q[i] == nil || *q[i] != *v {
+			return false
+		}
+	}
+	return true
+}
+
+// unifyTagPatterns returns a pattern that matches exactly the tags matched by both p and q.
+func unifyTagPatterns(p, q TagValues) (TagValues, bool) {
+	res := make(TagValues, max(len(p), len(q)))
+	for i := range res {
+		var a, b *string
+		if i < len(p) {
+			a = p[i]
+		}
+		if i < len(q) {
+			b = q[i]
+		}
+		switch {
+		case a == nil:
+			res[i] = b
+		case b == nil || *a == *b:
+			res[i] = a
+		default:
+			return nil, false
+		}
+	}
+	return res, true
+}
+
+func containsList[E comparable](outer, inner []E) bool {
+	if outer == nil {
+		return true
+	}
+	if inner == nil {
+		return false
+	}
+	for _, v := range inner {
+		if !slices.Contains(outer, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func intersectList[E comparable](a, b []E) []E {
+	if a == nil {
+		return slices.Clone(b)
+	}
+	if b == nil {
+		return slices.Clone(a)
+	}
+	res := make([]E, 0, min(len(a), len(b)))
+	for _, v := range a {
+		if slices.Contains(b, v) && !slices.Contains(res, v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func unionList[E comparable](a, b []E) []E {
+	if a == nil || b == nil {
+		return nil
+	}
+	res := slices.Clone(a)
+	for _, v := range b {
+		if !slices.Contains(res, v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,194 @@
+package nostr
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// these tests check the filter algebra against Filter.Matches using randomly generated
+// filters and events drawn from small alphabets, so collisions are frequent.
+
+func randomFilter(r *rand.Rand) Filter {
+	f := Filter{}
+	pick := func(options []string) []string {
+		if r.IntN(3) == 0 {
+			return nil
+		}
+		res := []string{}
+		for _, o := range options {
+			if r.IntN(2) == 0 {
+				res = append(res, o)
+			}
+		}
+		return res
+	}
+	f.IDs = pick([]string{"a", "b", "c"})
+	f.Authors = pick([]string{"x", "y"})
+	if r.IntN(3) != 0 {
+		f.Kinds = []int{}
+		for _, k := range []int{1, 2, 3} {
+			if r.IntN(2) == 0 {
+				f.Kinds = append(f.Kinds, k)
+			}
+		}
+	}
+	if r.IntN(2) == 0 {
+		since := Timestamp(r.IntN(6))
+		f.Since = &since
+	}
+	if r.IntN(2) == 0 {
+		until := Timestamp(r.IntN(6))
+		f.Until = &until
+	}
+	for _, name := range []string{"e", "t"} {
+		if r.IntN(2) == 0 {
+			continue
+		}
+		if f.Tags == nil {
+			f.Tags = make(TagMap)
+		}
+		sets := []TagValues{}
+		for range r.IntN(3) {
+			set := make(TagValues, r.IntN(3))
+			for i := range set {
+				if r.IntN(3) != 0 {
+					v := []string{"1", "2"}[r.IntN(2)]
+					set[i] = &v
+				}
+			}
+			sets = append(sets, set)
+		}
+		f.Tags[name] = sets
+	}
+	return f
+}
+
+func randomEvent(r *rand.Rand) *Event {
+	evt := &Event{
+		ID:        []string{"a", "b", "c"}[r.IntN(3)],
+		PubKey:    []string{"x", "y"}[r.IntN(2)],
+		Kind:      1 + r.IntN(3),
+		CreatedAt: Timestamp(r.IntN(6)),
+	}
+	for range r.IntN(4) {
+		tag := Tag{[]string{"e", "t"}[r.IntN(2)]}
+		for range 1 + r.IntN(2) {
+			tag = append(tag, []string{"1", "2"}[r.IntN(2)])
+		}
+		evt.Tags = append(evt.Tags, tag)
+	}
+	return evt
+}
+
+func TestFilterContainsProperty(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewPCG(1, 2))
+
+	found := 0
+	for range 20000 {
+		a, b := randomFilter(r), randomFilter(r)
+		if r.IntN(4) == 0 {
+			// make containment more likely
+			b, _ = a.Intersect(b)
+		}
+		if !a.Contains(b) {
+			continue
+		}
+		found++
+		for range 50 {
+			evt := randomEvent(r)
+			if b.Matches(evt) {
+				require.True(t, a.Matches(evt), "%s should contain %s, but doesn't match %s", a, b, evt)
+			}
+		}
+	}
+	require.Greater(t, found, 1000)
+}
+
+func TestFilterIntersectProperty(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewPCG(3, 4))
+
+	for range 20000 {
+		a, b := randomFilter(r), randomFilter(r)
+		i, ok := a.Intersect(b)
+		for range 50 {
+			evt := randomEvent(r)
+			both := a.Matches(evt) && b.Matches(evt)
+			if !ok {
+				require.False(t, both, "%s and %s were said to not intersect, but both match %s", a, b, evt)
+			} else {
+				require.Equal(t, both, i.Matches(evt), "%s ∩ %s = %s fails on %s", a, b, i, evt)
+			}
+		}
+	}
+}
+
+func TestFilterMergeProperty(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewPCG(5, 6))
+
+	merges := 0
+	for range 5000 {
+		filters := make(Filters, 1+r.IntN(3))
+		filters[0] = randomFilter(r)
+		for i := 1; i < len(filters); i++ {
+			// derive the others from the first so they are often mergeable
+			filters[i] = filters[0].Clone()
+			switch r.IntN(4) {
+			case 0:
+				filters[i].Kinds = randomFilter(r).Kinds
+			case 1:
+				filters[i].Authors = randomFilter(r).Authors
+			case 2:
+				other := randomFilter(r)
+				filters[i].Since, filters[i].Until = other.Since, other.Until
+			case 3:
+				filters[i] = randomFilter(r)
+			}
+		}
+
+		merged := filters.Merge()
+		if len(merged) < len(filters) {
+			merges++
+		}
+		for range 50 {
+			evt := randomEvent(r)
+			require.Equal(t, filters.Match(evt), merged.Match(evt), "%s merged into %s fails on %s", filters, merged, evt)
+		}
+	}
+	require.Greater(t, merges, 500)
+}
+
+func TestFilterMerge(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, Filters{{Kinds: []int{0}, Authors: []string{"aa", "bb", "cc"}}}, Filters{
+		{Kinds: []int{0}, Authors: []string{"aa"}},
+		{Kinds: []int{0}, Authors: []string{"bb"}},
+		{Kinds: []int{0}, Authors: []string{"cc", "aa"}},
+	}.Merge())
+
+	// different in two dimensions
+	require.Len(t, Filters{
+		{Kinds: []int{0}, Authors: []string{"aa"}},
+		{Kinds: []int{1}, Authors: []string{"bb"}},
+	}.Merge(), 2)
+
+	// limits prevent merging
+	require.Len(t, Filters{
+		{Kinds: []int{1}, Authors: []string{"aa"}, Limit: 10},
+		{Kinds: []int{1}, Authors: []string{"bb"}, Limit: 10},
+	}.Merge(), 2)
+}
+
+func TestFilterContainsLimit(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, Filter{Kinds: []int{1}, Limit: 10}.Contains(Filter{Kinds: []int{1}, Limit: 5}))
+	require.False(t, Filter{Kinds: []int{1}, Limit: 10}.Contains(Filter{Kinds: []int{1}, Limit: 20}))
+	require.False(t, Filter{Kinds: []int{1}, Limit: 10}.Contains(Filter{Kinds: []int{1}, Authors: []string{"aa"}, Limit: 5}))
+	require.True(t, Filter{Kinds: []int{1}}.Contains(Filter{Kinds: []int{1}, Authors: []string{"aa"}, Limit: 5}))
+}
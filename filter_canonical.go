@@ -0,0 +1,248 @@
+package nostr
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mailru/easyjson/jwriter"
+)
+
+var (
+	ErrFilterInvalidHex   = errors.New("must be 64 characters of lowercase hex")
+	ErrFilterInvalidKind  = errors.New("must be between 0 and 65535")
+	ErrFilterInvalidLimit = errors.New("must not be negative")
+	ErrFilterInvalidTag   = errors.New("tag name must not be empty")
+	ErrFilterEmptyValues  = errors.New("tag value list must not be empty")
+	ErrFilterTimeRange    = errors.New("since must not be after until")
+)
+
+// FilterError is returned by Filter.Validate for each problem found, use errors.Is() with
+// the ErrFilter* values to check what is wrong.
+type FilterError struct {
+	// Field is the JSON name of the offending field, like "ids", "kinds" or "#e".
+	Field string
+	// Index is the position of the offending item inside Field, or -1.
+	Index int
+	Err   error
+}
+
+func (e FilterError) Error() string {
+	if e.Index >= 0 {
+		return fmt.Sprintf("invalid filter %s[%d]: %s", e.Field, e.Index, e.Err)
+	}
+	return fmt.Sprintf("invalid filter %s: %s", e.Field, e.Err)
+}
+
+func (e FilterError) Unwrap() error { return e.Err }
+
+// Validate checks the filter against NIP-01 and returns all the problems found joined
+// together as FilterError values, or nil.
+func (ef Filter) Validate() error {
+	var errs []error
+
+	for i, id := range ef.IDs {
+		if !IsValid32ByteHex(id) {
+			errs = append(errs, FilterError{"ids", i, ErrFilterInvalidHex})
+		}
+	}
+	for i, pk := range ef.Authors {
+		if !IsValid32ByteHex(pk) {
+			errs = append(errs, FilterError{"authors", i, ErrFilterInvalidHex})
+		}
+	}
+	for i, kind := range ef.Kinds {
+		if kind < 0 || kind > 65535 {
+			errs = append(errs, FilterError{"kinds", i, ErrFilterInvalidKind})
+		}
+	}
+	if ef.Limit < 0 {
+		errs = append(errs, FilterError{"limit", -1, ErrFilterInvalidLimit})
+	}
+	if ef.Since != nil && ef.Until != nil && *ef.Since > *ef.Until {
+		errs = append(errs, FilterError{"since", -1, ErrFilterTimeRange})
+	}
+	for name, sets := range ef.Tags {
+		if name == "" {
+			errs = append(errs, FilterError{"#", -1, ErrFilterInvalidTag})
+			continue
+		}
+		if len(sets) == 0 {
+			errs = append(errs, FilterError{"#" + name, -1, ErrFilterEmptyValues})
+		}
+		for i, values := range sets {
+			// a nil is a wildcard, so {nil} is fine and matches any tag with this name
+			if len(values) == 0 {
+				errs = append(errs, FilterError{"#" + name, i, ErrFilterEmptyValues})
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Canonicalize returns a copy of the filter in a normalized form that matches exactly the same events:
+// all lists are sorted and deduplicated, negative limits become zero
+// and tag value lists have their trailing wildcards removed. A tag with a value list that is all wildcards
+// becomes a single {nil} list. Ids and authors are left as they are, since uppercase hex doesn't match
+// anything, so invalid ones are only reported by Validate. The canonical form of a valid filter is valid.
+//
+// Two filters that are equivalent in this sense will have the same Key().
+func (ef Filter) Canonicalize() Filter {
+	c := ef.Clone()
+
+	c.IDs = canonicalList(c.IDs)
+	c.Authors = canonicalList(c.Authors)
+	if c.Kinds != nil {
+		slices.Sort(c.Kinds)
+		c.Kinds = slices.Compact(c.Kinds)
+	}
+	if c.Limit < 0 {
+		c.Limit = 0
+	}
+	if c.Limit != 0 {
+		c.LimitZero = false
+	}
+
+	for name, sets := range c.Tags {
+		canonical := make([]TagValues, 0, len(sets))
+		for _, values := range sets {
+			// trailing nils are wildcards, they don't change what is matched
+			end := len(values)
+			for end > 0 && values[end-1] == nil {
+				end--
+			}
+			if end == 0 {
+				// a wildcard matches any tag with this name, so it supersedes all others
+				canonical = []TagValues{{nil}}
+				break
+			}
+			canonical = append(canonical, slices.Clone(values[:end]))
+		}
+		slices.SortFunc(canonical, compareTagValues)
+		c.Tags[name] = slices.CompactFunc(canonical, func(a, b TagValues) bool { return compareTagValues(a, b) == 0 })
+	}
+
+	return c
+}
+
+// Key returns a string that uniquely identifies the canonical form of the filter, suitable for use as a cache key.
+// It looks like the JSON form of the filter, but with tags sorted and empty lists included.
+func (ef Filter) Key() string {
+	c := ef.Canonicalize()
+	w := jwriter.Writer{NoEscapeHTML: true}
+	w.RawByte('{')
+
+	comma := func() {
+		if len(w.Buffer.Buf) > 1 {
+			w.RawByte(',')
+		}
+	}
+	writeStrings := func(name string, list []string) {
+		if list == nil {
+			return
+		}
+		comma()
+		w.RawString(`"` + name + `":[`)
+		for i, v := range list {
+			if i > 0 {
+				w.RawByte(',')
+			}
+			w.String(v)
+		}
+		w.RawByte(']')
+	}
+
+	writeStrings("ids", c.IDs)
+	if c.Kinds != nil {
+		comma()
+		w.RawString(`"kinds":[`)
+		for i, kind := range c.Kinds {
+			if i > 0 {
+				w.RawByte(',')
+			}
+			w.Int(kind)
+		}
+		w.RawByte(']')
+	}
+	writeStrings("authors", c.Authors)
+	if c.Since != nil {
+		comma()
+		w.RawString(`"since":`)
+		w.Int64(int64(*c.Since))
+	}
+	if c.Until != nil {
+		comma()
+		w.RawString(`"until":`)
+		w.Int64(int64(*c.Until))
+	}
+	if c.Limit != 0 || c.LimitZero {
+		comma()
+		w.RawString(`"limit":`)
+		w.Int(c.Limit)
+	}
+	if c.Search != "" {
+		comma()
+		w.RawString(`"search":`)
+		w.String(c.Search)
+	}
+
+	names := make([]string, 0, len(c.Tags))
+	for name := range c.Tags {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		comma()
+		w.String("#" + name)
+		w.RawString(":[")
+		for i, values := range c.Tags[name] {
+			if i > 0 {
+				w.RawByte(',')
+			}
+			w.RawByte('[')
+			for j, value := range values {
+				if j > 0 {
+					w.RawByte(',')
+				}
+				if value == nil {
+					w.RawString("null")
+				} else {
+					w.String(*value)
+				}
+			}
+			w.RawByte(']')
+		}
+		w.RawByte(']')
+	}
+
+	w.RawByte('}')
+	key, _ := w.BuildBytes()
+	return string(key)
+}
+
+func canonicalList(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	slices.Sort(list)
+	return slices.Compact(list)
+}
+
+func compareTagValues(a, b TagValues) int {
+	for i := range min(len(a), len(b)) {
+		switch {
+		case a[i] == nil && b[i] == nil:
+			continue
+		case a[i] == nil:
+			return -1
+		case b[i] == nil:
+			return 1
+		}
+		if c := strings.Compare(*a[i], *b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
@@ -0,0 +1,127 @@
+package nostr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterValidate(t *testing.T) {
+	t.Parallel()
+
+	pk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	require.NoError(t, Filter{
+		IDs:     []string{pk},
+		Authors: []string{pk},
+		Kinds:   []int{1, 30023},
+		Tags:    TagMap{}.SetLiterals("e", pk),
+		Limit:   10,
+	}.Validate())
+
+	// wildcards are fine
+	require.NoError(t, Filter{Tags: TagMap{"e": []TagValues{{nil}}, "p": []TagValues{{nil, &pk}}}}.Validate())
+
+	since, until := Timestamp(200), Timestamp(100)
+	err := Filter{
+		IDs:     []string{"abc"},
+		Authors: []string{pk, strings.ToUpper(pk)},
+		Kinds:   []int{-1},
+		Tags:    TagMap{"e": []TagValues{}, "p": []TagValues{{}}},
+		Limit:   -1,
+		Since:   &since,
+		Until:   &until,
+	}.Validate()
+	require.Error(t, err)
+
+	require.ErrorIs(t, err, ErrFilterInvalidHex)
+	require.ErrorIs(t, err, ErrFilterInvalidKind)
+	require.ErrorIs(t, err, ErrFilterInvalidLimit)
+	require.ErrorIs(t, err, ErrFilterEmptyValues)
+	require.ErrorIs(t, err, ErrFilterTimeRange)
+
+	var ferr FilterError
+	require.True(t, errors.As(err, &ferr))
+	require.Equal(t, "ids", ferr.Field)
+	require.Equal(t, 0, ferr.Index)
+	require.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 7)
+}
+
+func TestFilterCanonicalize(t *testing.T) {
+	t.Parallel()
+
+	a, b := "a", "b"
+	f := Filter{
+		IDs:     []string{"bb", "aa", "bb"},
+		Authors: []string{"CC", "cc"},
+		Kinds:   []int{7, 1, 7, 0},
+		Tags: TagMap{
+			"t": []TagValues{{&b}, {&a, nil}, {&b, nil, nil}},
+			"e": []TagValues{{&a}, {nil}},
+		},
+		Limit: -5,
+	}
+	c := f.Canonicalize()
+
+	require.Equal(t, []string{"aa", "bb"}, c.IDs)
+	require.Equal(t, []string{"CC", "cc"}, c.Authors, "uppercase hex is left for Validate")
+	require.Equal(t, []int{0, 1, 7}, c.Kinds)
+	require.Equal(t, 0, c.Limit)
+	require.Equal(t, []TagValues{{&a}, {&b}}, c.Tags["t"])
+	require.Equal(t, []TagValues{{nil}}, c.Tags["e"])
+
+	// the original is untouched
+	require.Equal(t, []string{"bb", "aa", "bb"}, f.IDs)
+	require.Equal(t, []int{7, 1, 7, 0}, f.Kinds)
+
+	// it matches the same events
+	evt := &Event{ID: strings.Repeat("ab", 32), Kind: 1}
+	upper := Filter{IDs: []string{strings.ToUpper(evt.ID)}}
+	require.False(t, upper.Matches(evt))
+	require.False(t, upper.Canonicalize().Matches(evt))
+}
+
+func TestFilterCanonicalIsValid(t *testing.T) {
+	t.Parallel()
+
+	pk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	x := "x"
+	for _, f := range []Filter{
+		{},
+		{IDs: []string{}, Kinds: []int{}},
+		{Authors: []string{pk, pk}, Kinds: []int{1, 1}},
+		{Tags: TagMap{"e": []TagValues{{nil}}}},
+		{Tags: TagMap{"e": []TagValues{{&x, nil}, {nil, nil}, {&x}}}},
+		{Tags: TagMap{"t": []TagValues{{&x}, {nil, &x}}}, Limit: 10},
+	} {
+		require.NoError(t, f.Canonicalize().Validate(), f.String())
+	}
+}
+
+func TestFilterKey(t *testing.T) {
+	t.Parallel()
+
+	f1 := Filter{
+		Kinds:   []int{1, 7},
+		Authors: []string{"aa", "aa"},
+		Tags:    TagMap{}.SetLiterals("t", "x").SetLiterals("e", "y"),
+	}
+	f2 := Filter{
+		Kinds:   []int{7, 1, 1},
+		Authors: []string{"aa"},
+		Tags:    TagMap{}.SetLiterals("e", "y").SetLiterals("t", "x"),
+	}
+	require.Equal(t, f1.Key(), f2.Key())
+	require.Equal(t, `{"kinds":[1,7],"authors":["aa"],"#e":[["y"]],"#t":[["x"]]}`, f1.Key())
+
+	f2.Kinds = []int{1}
+	require.NotEqual(t, f1.Key(), f2.Key())
+
+	require.Equal(t, `{"#e":[["y"]]}`, Filter{Tags: TagMap{}.SetLiterals("e", "y")}.Key())
+	require.Equal(t, `{}`, Filter{}.Key())
+	require.NotEqual(t, Filter{}.Key(), Filter{Kinds: []int{}}.Key(), "an empty list matches nothing")
+	require.NotEqual(t, Filter{}.Key(), Filter{IDs: []string{}}.Key(), "an empty list matches nothing")
+	require.Equal(t, `{"ids":[]}`, Filter{IDs: []string{}}.Key())
+	require.Equal(t, Filter{Tags: TagMap{"e": []TagValues{{nil}}}}.Key(), Filter{Tags: TagMap{"e": []TagValues{{nil, nil}}}}.Key())
+}
@@ -0,0 +1,92 @@
+package nostr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+)
+
+// ID is the binary form of an event id. It is comparable, so it can be used as a map key instead of
+// the hex string at half the size, and it marshals to and from the usual hex string.
+type ID [32]byte
+
+// PubKey is the binary form of a public key, see ID.
+type PubKey [32]byte
+
+var (
+	ZeroID     ID
+	ZeroPubKey PubKey
+)
+
+var ErrInvalid32ByteHex = errors.New("must be 64 characters of hex")
+
+// ParseID decodes a hex event id.
+func ParseID(s string) (ID, error) {
+	b, err := decode32ByteHex(s)
+	return ID(b), err
+}
+
+// ParsePubKey decodes a hex public key. It doesn't check if the key is a valid point on the curve,
+// for that use IsValidPublicKey.
+func ParsePubKey(s string) (PubKey, error) {
+	b, err := decode32ByteHex(s)
+	return PubKey(b), err
+}
+
+func (id ID) String() string               { return hex.EncodeToString(id[:]) }
+func (id ID) IsZero() bool                 { return id == ZeroID }
+func (id ID) Compare(other ID) int         { return bytes.Compare(id[:], other[:]) }
+func (id ID) MarshalText() ([]byte, error) { return encode32ByteHex(id), nil }
+func (id *ID) UnmarshalText(text []byte) (err error) {
+	*id, err = ParseID(string(text))
+	return err
+}
+
+func (pk PubKey) String() string               { return hex.EncodeToString(pk[:]) }
+func (pk PubKey) IsZero() bool                 { return pk == ZeroPubKey }
+func (pk PubKey) Compare(other PubKey) int     { return bytes.Compare(pk[:], other[:]) }
+func (pk PubKey) MarshalText() ([]byte, error) { return encode32ByteHex(pk), nil }
+func (pk *PubKey) UnmarshalText(text []byte) (err error) {
+	*pk, err = ParsePubKey(string(text))
+	return err
+}
+
+// ParsedID returns the binary form of evt.ID.
+func (evt *Event) ParsedID() (ID, error) { return ParseID(evt.ID) }
+
+// ParsedPubKey returns the binary form of evt.PubKey.
+func (evt *Event) ParsedPubKey() (PubKey, error) { return ParsePubKey(evt.PubKey) }
+
+func decode32ByteHex(s string) (dst [32]byte, err error) {
+	if len(s) != 64 {
+		return dst, ErrInvalid32ByteHex
+	}
+	// done by hand so the string isn't copied into a []byte
+	for i := range 32 {
+		hi, ok1 := fromHexChar(s[i*2])
+		lo, ok2 := fromHexChar(s[i*2+1])
+		if !ok1 || !ok2 {
+			return [32]byte{}, ErrInvalid32ByteHex
+		}
+		dst[i] = hi<<4 | lo
+	}
+	return dst, nil
+}
+
+func fromHexChar(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func encode32ByteHex(b [32]byte) []byte {
+	dst := make([]byte, 64)
+	hex.Encode(dst, b[:])
+	return dst
+}
@@ -0,0 +1,61 @@
+package nostr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDParsingAndMarshaling(t *testing.T) {
+	hexID := "92570b321da503eac8014b23447301eb3d0bbdfbace0d11a4e4072e72bb7205d"
+	id, err := ParseID(hexID)
+	require.NoError(t, err)
+	require.Equal(t, hexID, id.String())
+	require.False(t, id.IsZero())
+	require.True(t, ZeroID.IsZero())
+
+	upper, err := ParseID("92570B321DA503EAC8014B23447301EB3D0BBDFBACE0D11A4E4072E72BB7205D")
+	require.NoError(t, err)
+	require.Equal(t, id, upper)
+
+	for _, bad := range []string{"", "abc", hexID[:62] + "zz", hexID + "00"} {
+		parsed, err := ParseID(bad)
+		require.ErrorIs(t, err, ErrInvalid32ByteHex, bad)
+		require.True(t, parsed.IsZero())
+	}
+
+	pk, err := ParsePubKey("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	require.NoError(t, err)
+	require.Equal(t, -1, PubKey{}.Compare(pk))
+	require.Equal(t, 0, pk.Compare(pk))
+
+	type thing struct {
+		ID     ID     `json:"id"`
+		PubKey PubKey `json:"pubkey"`
+	}
+	j, err := json.Marshal(thing{id, pk})
+	require.NoError(t, err)
+	require.Equal(t, `{"id":"`+hexID+`","pubkey":"`+pk.String()+`"}`, string(j))
+
+	var back thing
+	require.NoError(t, json.Unmarshal(j, &back))
+	require.Equal(t, thing{id, pk}, back)
+	require.Error(t, json.Unmarshal([]byte(`{"id":"xyz"}`), &back))
+
+	require.Zero(t, testing.AllocsPerRun(100, func() { ParseID(hexID) }))
+}
+
+func TestEventParsedAccessors(t *testing.T) {
+	t.Parallel()
+
+	evt := Event{Kind: 1, Content: "hello", CreatedAt: 1700000000}
+	require.NoError(t, evt.Sign(GeneratePrivateKey()))
+
+	id, err := evt.ParsedID()
+	require.NoError(t, err)
+	require.Equal(t, evt.ComputeID(), [32]byte(id))
+
+	pk, err := evt.ParsedPubKey()
+	require.NoError(t, err)
+	require.Equal(t, evt.PubKey, pk.String())
+}
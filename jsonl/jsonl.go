@@ -0,0 +1,87 @@
+// Package jsonl reads and writes streams of events with one JSON event per line, which is the format used
+// by most relays and tools for backups and for moving events between relays.
+package jsonl
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Checkpoint is a position in a stream. It can be saved (it marshals to JSON) and given to Reader.Resume()
+// later so an interrupted import can continue where it stopped.
+type Checkpoint struct {
+	// Line is how many lines were consumed.
+	Line int64 `json:"line"`
+	// Offset is how many (decompressed) bytes were consumed.
+	Offset int64 `json:"offset"`
+}
+
+// ImportOptions control Import.
+type ImportOptions struct {
+	// CheckpointEvery is how many events are imported between calls to OnCheckpoint, 1000 by default.
+	CheckpointEvery int
+
+	// OnCheckpoint is called with the position right after the last event that was successfully saved,
+	// every CheckpointEvery events and at the end. If it returns an error the import stops.
+	OnCheckpoint func(Checkpoint) error
+}
+
+// Import reads all events from r and publishes them to store. It returns how many events were imported.
+// When it fails, the last checkpoint given to opts.OnCheckpoint can be used to resume it later.
+func Import(ctx context.Context, r *Reader, store nostr.RelayStore, opts ImportOptions) (int, error) {
+	if opts.CheckpointEvery <= 0 {
+		opts.CheckpointEvery = 1000
+	}
+	checkpoint := func() error {
+		if opts.OnCheckpoint == nil {
+			return nil
+		}
+		return opts.OnCheckpoint(r.Checkpoint())
+	}
+
+	imported := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return imported, err
+		}
+
+		evt, err := r.Next()
+		if err == io.EOF {
+			return imported, checkpoint()
+		} else if err != nil {
+			return imported, err
+		}
+
+		if err := store.Publish(ctx, *evt); err != nil {
+			return imported, fmt.Errorf("failed to save event %s: %w", evt.ID, err)
+		}
+
+		imported++
+		if imported%opts.CheckpointEvery == 0 {
+			if err := checkpoint(); err != nil {
+				return imported, err
+			}
+		}
+	}
+}
+
+// Export queries store with filter and writes all the results to w. It returns how many events were written.
+// w is flushed at the end.
+func Export(ctx context.Context, store nostr.RelayStore, filter nostr.Filter, w *Writer) (int, error) {
+	ch, err := store.QueryEvents(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query: %w", err)
+	}
+
+	start := w.Count()
+	for evt := range ch {
+		if err := w.WriteEvent(evt); err != nil {
+			return w.Count() - start, err
+		}
+	}
+
+	return w.Count() - start, w.Flush()
+}
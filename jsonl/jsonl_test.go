@@ -0,0 +1,173 @@
+package jsonl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/test_common"
+	"github.com/stretchr/testify/require"
+)
+
+func makeEvents(t *testing.T, n int) []*nostr.Event {
+	sk := nostr.GeneratePrivateKey()
+	events := make([]*nostr.Event, n)
+	for i := range events {
+		evt := &nostr.Event{Kind: 1, CreatedAt: nostr.Timestamp(1700000000 + i), Content: fmt.Sprintf("event %d", i)}
+		require.NoError(t, evt.Sign(sk))
+		events[i] = evt
+	}
+	return events
+}
+
+func writeAll(t *testing.T, w io.Writer, events []*nostr.Event) {
+	jw := NewWriter(w)
+	for _, evt := range events {
+		require.NoError(t, jw.WriteEvent(evt))
+	}
+	require.NoError(t, jw.Flush())
+}
+
+func readAll(t *testing.T, r *Reader) []*nostr.Event {
+	var events []*nostr.Event
+	for {
+		evt, err := r.Next()
+		if err == io.EOF {
+			return events
+		}
+		require.NoError(t, err)
+		events = append(events, evt)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	events := makeEvents(t, 20)
+
+	// duplicates are skipped
+	plain := &bytes.Buffer{}
+	writeAll(t, plain, append(events, events[3], events[4]))
+	require.Equal(t, 20, strings.Count(plain.String(), "\n"))
+
+	compressed := &bytes.Buffer{}
+	gw := gzip.NewWriter(compressed)
+	writeAll(t, gw, events)
+	require.NoError(t, gw.Close())
+
+	for _, buf := range []*bytes.Buffer{plain, compressed} {
+		r, err := NewReader(buf)
+		require.NoError(t, err)
+		require.Equal(t, events, readAll(t, r))
+	}
+
+	_, err := NewReader(bytes.NewReader([]byte{0x28, 0xb5, 0x2f, 0xfd, 0, 0}))
+	require.ErrorIs(t, err, ErrNoDecompressor)
+}
+
+func TestWriteRawJSON(t *testing.T) {
+	events := makeEvents(t, 2)
+
+	// as a relay could send them, pretty-printed and with an extra field
+	var raws []*nostr.Event
+	for _, evt := range events {
+		pretty := fmt.Sprintf("{\n  \"id\": %q,\n  \"pubkey\": %q,\n  \"created_at\": %d,\n  \"kind\": %d,\n"+
+			"  \"tags\": [],\n  \"content\": %q,\n  \"sig\": %q,\n  \"extra\": true\n}",
+			evt.ID, evt.PubKey, evt.CreatedAt, evt.Kind, evt.Content, evt.Sig)
+		raw := &nostr.Event{}
+		require.NoError(t, raw.UnmarshalJSONWithRaw([]byte(pretty)))
+		_, ok := raw.RawJSON()
+		require.True(t, ok)
+		raws = append(raws, raw)
+	}
+
+	buf := &bytes.Buffer{}
+	writeAll(t, buf, raws)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"extra":true`)
+
+	r, err := NewReader(buf)
+	require.NoError(t, err)
+	read := readAll(t, r)
+	require.Len(t, read, 2)
+	for i, evt := range read {
+		require.Equal(t, events[i].ID, evt.ID)
+		require.Equal(t, events[i].Sig, evt.Sig)
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+	events := makeEvents(t, 3)
+	tampered := *events[1]
+	tampered.Content = "tampered"
+
+	stream := events[0].String() + "\n\n" +
+		tampered.String() + "\n" +
+		"not json\n" +
+		`{"kind":1,"content":"` + strings.Repeat("x", 2000) + `"}` + "\n" +
+		events[2].String() // no trailing newline
+
+	r, _ := NewReader(strings.NewReader(stream))
+	_, err := r.Next()
+	require.NoError(t, err)
+	_, err = r.Next()
+	var lerr *LineError
+	require.True(t, errors.As(err, &lerr))
+	require.Equal(t, int64(3), lerr.Line)
+	require.ErrorIs(t, err, nostr.ErrEventIDMismatch)
+
+	r, _ = NewReader(strings.NewReader(stream))
+	r.Policy = SkipInvalid
+	r.MaxLineSize = 1000
+	var skipped []int64
+	r.OnError = func(err *LineError) { skipped = append(skipped, err.Line) }
+	require.Equal(t, []*nostr.Event{events[0], events[2]}, readAll(t, r))
+	require.Equal(t, []int64{3, 4, 5}, skipped)
+
+	// without verification the tampered event goes through
+	r, _ = NewReader(strings.NewReader(stream))
+	r.Policy = SkipInvalid
+	r.Verify = false
+	require.Len(t, readAll(t, r), 4)
+}
+
+func TestImportExportResume(t *testing.T) {
+	ctx := context.Background()
+	events := makeEvents(t, 25)
+
+	source := &test_common.MemoryStore{Events: events}
+	backup := &bytes.Buffer{}
+	n, err := Export(ctx, source, nostr.Filter{Kinds: []int{1}}, NewWriter(backup))
+	require.NoError(t, err)
+	require.Equal(t, 25, n)
+
+	// the import fails halfway
+	target := &test_common.MemoryStore{FailPublishAt: 12}
+	var last Checkpoint
+	opts := ImportOptions{CheckpointEvery: 5, OnCheckpoint: func(cp Checkpoint) error { last = cp; return nil }}
+
+	r, _ := NewReader(bytes.NewReader(backup.Bytes()))
+	n, err = Import(ctx, r, target, opts)
+	require.Error(t, err)
+	require.Equal(t, 12, n)
+	require.Equal(t, int64(10), last.Line)
+
+	// then continues from the last checkpoint
+	target.Events = target.Events[:last.Line]
+	r, _ = NewReader(bytes.NewReader(backup.Bytes()))
+	require.NoError(t, r.Resume(last))
+	n, err = Import(ctx, r, target, opts)
+	require.NoError(t, err)
+	require.Equal(t, 15, n)
+	require.Equal(t, events, target.Events)
+	require.Equal(t, int64(25), last.Line)
+	require.Equal(t, int64(backup.Len()), last.Offset)
+
+	r, _ = NewReader(bytes.NewReader(backup.Bytes()[:10]))
+	require.ErrorIs(t, r.Resume(last), ErrCheckpointPastEnd)
+}
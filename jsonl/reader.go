@@ -0,0 +1,227 @@
+package jsonl
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mailru/easyjson"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// ErrorPolicy tells a Reader what to do with lines that can't be parsed or verified.
+type ErrorPolicy int
+
+const (
+	// StopOnError makes Next() return the first bad line as a *LineError, the default.
+	StopOnError ErrorPolicy = iota
+	// SkipInvalid makes Next() skip bad lines, reporting each to Reader.OnError.
+	SkipInvalid
+)
+
+var (
+	ErrLineTooLong       = errors.New("line is too long")
+	ErrNoDecompressor    = errors.New("stream is compressed with an unsupported algorithm")
+	ErrAlreadyStarted    = errors.New("can't resume a reader that was already used")
+	ErrCheckpointPastEnd = errors.New("checkpoint is past the end of the stream")
+)
+
+// LineError is the error for a line that couldn't be parsed or verified.
+type LineError struct {
+	// Line is 1-based.
+	Line int64
+	Err  error
+}
+
+func (e *LineError) Error() string { return fmt.Sprintf("line %d: %s", e.Line, e.Err) }
+func (e *LineError) Unwrap() error { return e.Err }
+
+// Decompressor wraps a compressed stream, recognized by the magic bytes at its start.
+type Decompressor struct {
+	Name      string
+	Magic     []byte
+	NewReader func(io.Reader) (io.Reader, error)
+}
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Decompressors are checked against the start of every stream given to NewReader. gzip is supported out of the box,
+// zstd streams are recognized but need a decompressor to be added here, for example with klauspost/compress:
+//
+//	jsonl.Decompressors = append(jsonl.Decompressors, jsonl.Decompressor{
+//		Name:      "zstd",
+//		Magic:     []byte{0x28, 0xb5, 0x2f, 0xfd},
+//		NewReader: func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+//	})
+var Decompressors = []Decompressor{
+	{
+		Name:      "gzip",
+		Magic:     []byte{0x1f, 0x8b},
+		NewReader: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	},
+}
+
+// Reader reads events from a stream with one JSON event per line, like the ones produced by Writer.
+type Reader struct {
+	// Verify checks the id and signature (and everything else, see nostr.Event.Validate) of each event.
+	// It is true by default.
+	Verify bool
+
+	// Policy tells what to do with bad lines, see ErrorPolicy.
+	Policy ErrorPolicy
+
+	// OnError is called for each line skipped because of SkipInvalid.
+	OnError func(err *LineError)
+
+	// MaxLineSize is the size of the longest line that will be accepted, 4MB by default.
+	MaxLineSize int
+
+	r      *bufio.Reader
+	line   int64
+	offset int64
+	buf    []byte
+}
+
+// NewReader creates a Reader, detecting and handling compressed streams.
+func NewReader(r io.Reader) (*Reader, error) {
+	br, err := decompress(bufio.NewReaderSize(r, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		Verify:      true,
+		MaxLineSize: 4 * 1024 * 1024,
+		r:           br,
+	}, nil
+}
+
+func decompress(br *bufio.Reader) (*bufio.Reader, error) {
+	start, _ := br.Peek(4)
+
+	for _, d := range Decompressors {
+		if bytes.HasPrefix(start, d.Magic) {
+			dr, err := d.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("failed to start %s decompression: %w", d.Name, err)
+			}
+			return bufio.NewReaderSize(dr, 64*1024), nil
+		}
+	}
+	if bytes.HasPrefix(start, zstdMagic) {
+		return nil, fmt.Errorf("%w: zstd (add it to jsonl.Decompressors)", ErrNoDecompressor)
+	}
+
+	return br, nil
+}
+
+// Checkpoint returns the current position in the stream, right after the last event returned by Next().
+func (r *Reader) Checkpoint() Checkpoint {
+	return Checkpoint{Line: r.line, Offset: r.offset}
+}
+
+// Resume skips everything up to the given checkpoint, so reading continues from where it was taken.
+// It must be called before Next() and the stream must be the same one (compressed or not) the checkpoint
+// was taken from.
+func (r *Reader) Resume(cp Checkpoint) error {
+	if r.line != 0 || r.offset != 0 {
+		return ErrAlreadyStarted
+	}
+	n, err := io.CopyN(io.Discard, r.r, cp.Offset)
+	r.offset = n
+	if err != nil {
+		if err == io.EOF {
+			return ErrCheckpointPastEnd
+		}
+		return err
+	}
+	r.line = cp.Line
+	return nil
+}
+
+// Next returns the next event in the stream, or io.EOF when it ends.
+func (r *Reader) Next() (*nostr.Event, error) {
+	for {
+		line, err := r.readLine()
+		if err != nil && err != ErrLineTooLong {
+			return nil, err
+		}
+
+		var evt *nostr.Event
+		if err == nil {
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 {
+				continue
+			}
+			evt, err = r.parse(line)
+		}
+
+		if err != nil {
+			lerr := &LineError{Line: r.line, Err: err}
+			if r.Policy == SkipInvalid {
+				if r.OnError != nil {
+					r.OnError(lerr)
+				}
+				continue
+			}
+			return nil, lerr
+		}
+
+		return evt, nil
+	}
+}
+
+func (r *Reader) parse(line []byte) (*nostr.Event, error) {
+	evt := &nostr.Event{}
+	if err := easyjson.Unmarshal(line, evt); err != nil {
+		return nil, err
+	}
+	if r.Verify {
+		if err := evt.Validate(nostr.ValidateOptions{}); err != nil {
+			return nil, err
+		}
+	}
+	return evt, nil
+}
+
+// readLine returns the next line, without copying it unless it is longer than the buffer.
+func (r *Reader) readLine() ([]byte, error) {
+	r.buf = r.buf[:0]
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		r.offset += int64(len(chunk))
+
+		if len(r.buf)+len(chunk) > r.MaxLineSize {
+			// skip the rest of this line so we can keep going
+			for err == bufio.ErrBufferFull {
+				chunk, err = r.r.ReadSlice('\n')
+				r.offset += int64(len(chunk))
+			}
+			r.line++
+			return nil, ErrLineTooLong
+		}
+
+		switch err {
+		case nil:
+			r.line++
+			if len(r.buf) == 0 {
+				return chunk, nil
+			}
+			return append(r.buf, chunk...), nil
+		case bufio.ErrBufferFull:
+			r.buf = append(r.buf, chunk...)
+		case io.EOF:
+			if len(r.buf)+len(chunk) == 0 {
+				return nil, io.EOF
+			}
+			// last line without a trailing newline
+			r.line++
+			return append(r.buf, chunk...), nil
+		default:
+			return nil, err
+		}
+	}
+}
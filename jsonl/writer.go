@@ -0,0 +1,77 @@
+package jsonl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/mailru/easyjson/jwriter"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Writer writes events to a stream, one JSON event per line. Events that keep their original JSON
+// (see nostr.Event.RawJSON) are written as they were received, only compacted if they span many lines.
+type Writer struct {
+	// Dedup makes the writer skip events it has already written, it is true by default.
+	Dedup bool
+
+	w       *bufio.Writer
+	seen    map[nostr.ID]struct{}
+	count   int
+	compact bytes.Buffer
+}
+
+// NewWriter creates a Writer. Flush() must be called when done. To write a compressed stream just wrap w,
+// for example with gzip.NewWriter().
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		Dedup: true,
+		w:     bufio.NewWriterSize(w, 64*1024),
+		seen:  make(map[nostr.ID]struct{}),
+	}
+}
+
+// WriteEvent writes a single event, unless it is a duplicate.
+func (w *Writer) WriteEvent(evt *nostr.Event) error {
+	if w.Dedup {
+		if id, err := evt.ParsedID(); err == nil {
+			if _, ok := w.seen[id]; ok {
+				return nil
+			}
+			w.seen[id] = struct{}{}
+		}
+	}
+
+	if raw, ok := evt.RawJSON(); ok {
+		if bytes.ContainsAny(raw, "\r\n") {
+			// pretty-printed JSON would break the one event per line format
+			w.compact.Reset()
+			if err := json.Compact(&w.compact, raw); err != nil {
+				return err
+			}
+			raw = w.compact.Bytes()
+		}
+		if _, err := w.w.Write(raw); err != nil {
+			return err
+		}
+	} else {
+		jw := jwriter.Writer{NoEscapeHTML: true}
+		evt.MarshalEasyJSON(&jw)
+		if _, err := jw.DumpTo(w.w); err != nil {
+			return err
+		}
+	}
+	if err := w.w.WriteByte('\n'); err != nil {
+		return err
+	}
+
+	w.count++
+	return nil
+}
+
+// Count returns how many events were written so far.
+func (w *Writer) Count() int { return w.count }
+
+// Flush writes any buffered data to the underlying writer.
+func (w *Writer) Flush() error { return w.w.Flush() }
@@ -30,6 +30,7 @@ const (
 	KindOpenTimestamps           int = 1040
 	KindGiftWrap                 int = 1059
 	KindFileMetadata             int = 1063
+	KindComment                  int = 1111
 	KindLiveChatMessage          int = 1311
 	KindPatch                    int = 1617
 	KindIssue                    int = 1621
@@ -0,0 +1,168 @@
+package nip09
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var ErrDeleted = errors.New("event was deleted by its author")
+
+type idKey struct {
+	id     string
+	author string
+}
+
+// Index keeps the deletion requests it has seen and tells which events they delete. It is safe for
+// concurrent use.
+type Index struct {
+	mu        sync.RWMutex
+	ids       map[idKey]struct{}
+	addresses map[nostr.Address]nostr.Timestamp
+}
+
+func NewIndex() *Index {
+	return &Index{
+		ids:       make(map[idKey]struct{}),
+		addresses: make(map[nostr.Address]nostr.Timestamp),
+	}
+}
+
+// Add records a deletion request, returns false if evt is not one.
+// Addresses of other authors are ignored, since they can't be deleted by this request anyway.
+func (idx *Index) Add(evt *nostr.Event) bool {
+	d, ok := ParseDeletion(evt)
+	if !ok {
+		return false
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, id := range d.IDs {
+		idx.ids[idKey{id, d.Author}] = struct{}{}
+	}
+	for _, addr := range d.Addresses {
+		if addr.PubKey == d.Author && d.CreatedAt > idx.addresses[addr] {
+			idx.addresses[addr] = d.CreatedAt
+		}
+	}
+	return true
+}
+
+// IsDeleted tells if any of the deletion requests seen so far applies to evt.
+func (idx *Index) IsDeleted(evt *nostr.Event) bool {
+	if evt.Kind == nostr.KindDeletion {
+		return false
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if _, ok := idx.ids[idKey{evt.ID, evt.PubKey}]; ok {
+		return true
+	}
+	if addr, ok := evt.Address(); ok {
+		if until, ok := idx.addresses[addr]; ok && evt.CreatedAt <= until {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the events that are not deleted, reusing the given slice.
+func (idx *Index) Filter(events []*nostr.Event) []*nostr.Event {
+	res := events[:0]
+	for _, evt := range events {
+		if !idx.IsDeleted(evt) {
+			res = append(res, evt)
+		}
+	}
+	clear(events[len(res):])
+	return res
+}
+
+// Load adds all the deletion requests found in the store.
+func (idx *Index) Load(ctx context.Context, s nostr.RelayStore) error {
+	deletions, err := s.QuerySync(ctx, nostr.Filter{Kinds: []int{nostr.KindDeletion}})
+	if err != nil {
+		return fmt.Errorf("failed to query deletions: %w", err)
+	}
+	for _, evt := range deletions {
+		idx.Add(evt)
+	}
+	return nil
+}
+
+// Apply removes from events the ones deleted by the deletion requests among them. Deletion requests are
+// kept. The given slice is reused.
+func Apply(events []*nostr.Event) []*nostr.Event {
+	idx := NewIndex()
+	for _, evt := range events {
+		idx.Add(evt)
+	}
+	return idx.Filter(events)
+}
+
+// Policy returns a pool policy (see nostr.WithEventPolicy) that records every deletion request received
+// and drops events deleted by them. Events received before the deletion that deletes them are not
+// retracted, so it should be combined with Filter or a Store for anything that is kept around.
+func (idx *Index) Policy() nostr.EventPolicy {
+	return func(ie nostr.RelayEvent) (nostr.RelayEvent, error) {
+		if idx.Add(ie.Event) {
+			return ie, nil
+		}
+		if idx.IsDeleted(ie.Event) {
+			return ie, ErrDeleted
+		}
+		return ie, nil
+	}
+}
+
+// Store wraps a RelayStore so deleted events are neither saved nor returned, for example when used
+// as a local store with nostr.WithRelayStore. Call Index.Load first so deletions that are already in
+// the store are taken into account.
+type Store struct {
+	nostr.RelayStore
+	Index *Index
+}
+
+var _ nostr.RelayStore = Store{}
+
+func (s Store) Publish(ctx context.Context, evt nostr.Event) error {
+	if !s.Index.Add(&evt) && s.Index.IsDeleted(&evt) {
+		return ErrDeleted
+	}
+	return s.RelayStore.Publish(ctx, evt)
+}
+
+func (s Store) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
+	ch, err := s.RelayStore.QueryEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make(chan *nostr.Event)
+	go func() {
+		defer close(filtered)
+		for evt := range ch {
+			if s.Index.IsDeleted(evt) {
+				continue
+			}
+			select {
+			case filtered <- evt:
+			case <-ctx.Done():
+				// keep draining so the underlying store isn't blocked
+			}
+		}
+	}()
+	return filtered, nil
+}
+
+func (s Store) QuerySync(ctx context.Context, filter nostr.Filter) ([]*nostr.Event, error) {
+	events, err := s.RelayStore.QuerySync(ctx, filter)
+	return s.Index.Filter(events), err
+}
@@ -0,0 +1,116 @@
+package nip09
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Deletion is a NIP-09 deletion request.
+type Deletion struct {
+	Author    string
+	CreatedAt nostr.Timestamp
+
+	IDs       []string
+	Addresses []nostr.Address
+	Kinds     []int
+	Reason    string
+}
+
+// ParseDeletion reads a kind 5 event. It returns false for other kinds.
+func ParseDeletion(evt *nostr.Event) (Deletion, bool) {
+	if evt.Kind != nostr.KindDeletion {
+		return Deletion{}, false
+	}
+
+	d := Deletion{
+		Author:    evt.PubKey,
+		CreatedAt: evt.CreatedAt,
+		Reason:    evt.Content,
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "e":
+			if nostr.IsValid32ByteHex(tag[1]) {
+				d.IDs = append(d.IDs, tag[1])
+			}
+		case "a":
+			if addr, err := nostr.ParseAddress(tag[1]); err == nil {
+				d.Addresses = append(d.Addresses, addr)
+			}
+		case "k":
+			if kind, err := strconv.Atoi(tag[1]); err == nil {
+				d.Kinds = append(d.Kinds, kind)
+			}
+		}
+	}
+
+	return d, true
+}
+
+// Event creates the unsigned kind 5 event for this deletion request. Author and CreatedAt are ignored,
+// they come from signing and the current time. When Kinds is empty the "k" tags are derived from the
+// addresses only, since the kinds of the events in IDs are not known.
+func (d Deletion) Event() nostr.Event {
+	tags := make(nostr.Tags, 0, len(d.IDs)+len(d.Addresses)+len(d.Kinds)+1)
+	for _, id := range d.IDs {
+		tags = append(tags, nostr.Tag{"e", id})
+	}
+
+	kinds := slices.Clone(d.Kinds)
+	for _, addr := range d.Addresses {
+		tags = append(tags, nostr.Tag{"a", addr.String()})
+		if len(d.Kinds) == 0 {
+			kinds = append(kinds, addr.Kind)
+		}
+	}
+
+	slices.Sort(kinds)
+	for _, kind := range slices.Compact(kinds) {
+		tags = append(tags, nostr.Tag{"k", strconv.Itoa(kind)})
+	}
+
+	return nostr.Event{
+		Kind:      nostr.KindDeletion,
+		CreatedAt: nostr.Now(),
+		Tags:      tags,
+		Content:   d.Reason,
+	}
+}
+
+// MakeDeletion creates an unsigned deletion request for the given events, with "e" tags for all of them,
+// "a" tags for the replaceable and addressable ones (so all their versions up to now are deleted)
+// and "k" tags with their kinds.
+func MakeDeletion(reason string, events ...*nostr.Event) nostr.Event {
+	d := Deletion{Reason: reason}
+	for _, evt := range events {
+		d.IDs = append(d.IDs, evt.ID)
+		if addr, ok := evt.Address(); ok && !slices.Contains(d.Addresses, addr) {
+			d.Addresses = append(d.Addresses, addr)
+		}
+		d.Kinds = append(d.Kinds, evt.Kind)
+	}
+	return d.Event()
+}
+
+// Deletes tells if this deletion request applies to evt: it must be from the same author and either
+// point to its id or to its address, in which case only versions created up to the deletion are deleted.
+// Deletion requests can't be deleted.
+func (d Deletion) Deletes(evt *nostr.Event) bool {
+	if evt.PubKey != d.Author || evt.Kind == nostr.KindDeletion {
+		return false
+	}
+	if slices.Contains(d.IDs, evt.ID) {
+		return true
+	}
+	if evt.CreatedAt <= d.CreatedAt {
+		if addr, ok := evt.Address(); ok && slices.Contains(d.Addresses, addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package nip09
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/test_common"
+	"github.com/stretchr/testify/require"
+)
+
+func signed(t *testing.T, sk string, evt nostr.Event) *nostr.Event {
+	require.NoError(t, evt.Sign(sk))
+	return &evt
+}
+
+func TestDeletion(t *testing.T) {
+	alice, bob := nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey()
+
+	note := signed(t, alice, nostr.Event{Kind: 1, CreatedAt: 100, Content: "oops"})
+	kept := signed(t, alice, nostr.Event{Kind: 1, CreatedAt: 101, Content: "fine"})
+	bobNote := signed(t, bob, nostr.Event{Kind: 1, CreatedAt: 100, Content: "bob"})
+	oldArticle := signed(t, alice, nostr.Event{Kind: nostr.KindArticle, CreatedAt: 100, Tags: nostr.Tags{{"d", "x"}}})
+	newArticle := signed(t, alice, nostr.Event{Kind: nostr.KindArticle, CreatedAt: 300, Tags: nostr.Tags{{"d", "x"}}})
+
+	evt := MakeDeletion("mistakes", note, bobNote, oldArticle)
+	evt.CreatedAt = 200
+	deletion := signed(t, alice, evt)
+
+	addr, _ := oldArticle.Address()
+	require.Equal(t, nostr.Tags{
+		{"e", note.ID},
+		{"e", bobNote.ID},
+		{"e", oldArticle.ID},
+		{"a", addr.String()},
+		{"k", "1"},
+		{"k", "30023"},
+	}, deletion.Tags)
+
+	d, ok := ParseDeletion(deletion)
+	require.True(t, ok)
+	require.Equal(t, "mistakes", d.Reason)
+	require.True(t, d.Deletes(note))
+	require.False(t, d.Deletes(kept))
+	require.False(t, d.Deletes(bobNote)) // only the author can delete
+	require.True(t, d.Deletes(oldArticle))
+	require.False(t, d.Deletes(newArticle)) // newer than the deletion
+
+	res := Apply([]*nostr.Event{note, kept, bobNote, deletion, oldArticle, newArticle})
+	require.Equal(t, []*nostr.Event{kept, bobNote, deletion, newArticle}, res)
+
+	// deletions can't be deleted
+	undo := signed(t, alice, MakeDeletion("", deletion))
+	require.Equal(t, []*nostr.Event{deletion, undo}, Apply([]*nostr.Event{deletion, undo}))
+}
+
+func TestStoreAndPolicy(t *testing.T) {
+	ctx := context.Background()
+	sk := nostr.GeneratePrivateKey()
+	note := signed(t, sk, nostr.Event{Kind: 1, CreatedAt: 100})
+	deletion := signed(t, sk, MakeDeletion("", note))
+
+	inner := &test_common.MemoryStore{}
+	inner.Publish(ctx, *note)
+	inner.Publish(ctx, *deletion)
+
+	// deletions already stored are loaded
+	idx := NewIndex()
+	require.NoError(t, idx.Load(ctx, inner))
+	store := Store{RelayStore: inner, Index: idx}
+
+	res, err := store.QuerySync(ctx, nostr.Filter{Kinds: []int{1}})
+	require.NoError(t, err)
+	require.Empty(t, res)
+	require.ErrorIs(t, store.Publish(ctx, *note), ErrDeleted)
+
+	ch, err := store.QueryEvents(ctx, nostr.Filter{})
+	require.NoError(t, err)
+	var all []*nostr.Event
+	for evt := range ch {
+		all = append(all, evt)
+	}
+	require.Len(t, all, 1)
+	require.Equal(t, deletion.ID, all[0].ID)
+
+	// the policy learns deletions as they come
+	policy := NewIndex().Policy()
+	_, err = policy(nostr.RelayEvent{Event: note})
+	require.NoError(t, err)
+	_, err = policy(nostr.RelayEvent{Event: deletion})
+	require.NoError(t, err)
+	_, err = policy(nostr.RelayEvent{Event: note})
+	require.ErrorIs(t, err, ErrDeleted)
+}
@@ -53,3 +53,37 @@ func GetImmediateReply(tags nostr.Tags) *nostr.Tag {
 	// if we reached this point and we have at least one "e" we'll use that (the last)
 	return lastE
 }
+
+// ReplyTags returns the tags for a new reply to parent: a "root" marked "e" tag, a "reply" marked "e"
+// tag (unless parent is itself the root), the parent author and all the "p" tags from the parent.
+// relay is where parent can be found, it is used as a hint in the tags that point to it.
+func ReplyTags(parent *nostr.Event, relay string) nostr.Tags {
+	tags := make(nostr.Tags, 0, 3+len(parent.Tags))
+
+	root := GetThreadRoot(parent.Tags)
+	if root != nil && len(*root) >= 4 && (*root)[3] == "mention" {
+		root = nil
+	}
+
+	if root == nil {
+		tags = append(tags, nostr.Tag{"e", parent.ID, relay, "root", parent.PubKey})
+	} else {
+		rootTag := nostr.Tag{"e", (*root)[1], "", "root"}
+		if len(*root) > 2 {
+			rootTag[2] = (*root)[2]
+		}
+		if len(*root) > 4 && (*root)[4] != "" {
+			rootTag = append(rootTag, (*root)[4])
+		}
+		tags = append(tags, rootTag, nostr.Tag{"e", parent.ID, relay, "reply", parent.PubKey})
+	}
+
+	tags = append(tags, nostr.Tag{"p", parent.PubKey})
+	for _, tag := range parent.Tags {
+		if len(tag) >= 2 && tag[0] == "p" && tag[1] != parent.PubKey {
+			tags = tags.AppendUnique(tag)
+		}
+	}
+
+	return tags
+}
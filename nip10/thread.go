@@ -0,0 +1,330 @@
+package nip10
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Node is an event in a Thread. Event is nil for events that are referenced as parents but weren't found.
+type Node struct {
+	ID      string
+	Event   *nostr.Event
+	Parent  *Node
+	Replies []*Node
+}
+
+// Thread is a tree of replies built from NIP-10 "e" tags.
+type Thread struct {
+	Root  *Node
+	Nodes map[string]*Node
+
+	// Missing has the events that are referenced as parents (or the root) but weren't found, with the
+	// relay hints and authors taken from the tags that point to them. Their nodes have no Event and,
+	// since we don't know where they belong, are placed right under the root.
+	Missing []nostr.EventPointer
+}
+
+// BuildThread arranges the given events into a thread under root, using marked "e" tags when present
+// and positional ones otherwise (see GetImmediateReply). Events that don't reply to anything are ignored,
+// replies to "a" tags are considered direct replies to the root and siblings are sorted from oldest to
+// newest.
+func BuildThread(root nostr.EventPointer, events []*nostr.Event) *Thread {
+	thread := &Thread{Nodes: make(map[string]*Node, len(events)+1)}
+	thread.Root = &Node{ID: root.ID}
+	thread.Nodes[root.ID] = thread.Root
+
+	for _, evt := range events {
+		if evt.ID == root.ID {
+			thread.Root.Event = evt
+		} else if _, exists := thread.Nodes[evt.ID]; !exists && GetImmediateReply(evt.Tags) != nil {
+			thread.Nodes[evt.ID] = &Node{ID: evt.ID, Event: evt}
+		}
+	}
+
+	missing := make(map[string]*nostr.EventPointer)
+	if thread.Root.Event == nil {
+		missing[root.ID] = &nostr.EventPointer{ID: root.ID, Relays: root.Relays, Author: root.Author, Kind: root.Kind}
+	}
+
+	// find the parents
+	parentOf := make(map[string]string, len(thread.Nodes))
+	for id, node := range thread.Nodes {
+		if node == thread.Root {
+			continue
+		}
+
+		tag := GetImmediateReply(node.Event.Tags)
+		if (*tag)[0] != "e" || (*tag)[1] == id {
+			parentOf[id] = root.ID
+			continue
+		}
+
+		parentID := (*tag)[1]
+		parentOf[id] = parentID
+
+		if _, exists := thread.Nodes[parentID]; !exists || (parentID == root.ID && thread.Root.Event == nil) {
+			ptr, ok := missing[parentID]
+			if !ok {
+				ptr = &nostr.EventPointer{ID: parentID}
+				missing[parentID] = ptr
+			}
+			if len(*tag) > 2 && (*tag)[2] != "" && !slices.Contains(ptr.Relays, (*tag)[2]) {
+				ptr.Relays = append(ptr.Relays, (*tag)[2])
+			}
+			if len(*tag) > 4 && ptr.Author == "" && nostr.IsValid32ByteHex((*tag)[4]) {
+				ptr.Author = (*tag)[4]
+			}
+		}
+	}
+
+	for id := range missing {
+		if _, exists := thread.Nodes[id]; !exists {
+			thread.Nodes[id] = &Node{ID: id}
+			parentOf[id] = root.ID
+		}
+	}
+
+	// break reply cycles, which can only come from bad events, by moving them under the root
+	for _, id := range slices.Sorted(maps.Keys(parentOf)) {
+		seen := map[string]bool{id: true}
+		for curr := parentOf[id]; curr != root.ID; curr = parentOf[curr] {
+			if seen[curr] {
+				parentOf[id] = root.ID
+				break
+			}
+			seen[curr] = true
+		}
+	}
+
+	for id, parentID := range parentOf {
+		node, parent := thread.Nodes[id], thread.Nodes[parentID]
+		node.Parent = parent
+		parent.Replies = append(parent.Replies, node)
+	}
+	for _, node := range thread.Nodes {
+		slices.SortFunc(node.Replies, compareNodes)
+	}
+
+	thread.Missing = make([]nostr.EventPointer, 0, len(missing))
+	for _, ptr := range missing {
+		thread.Missing = append(thread.Missing, *ptr)
+	}
+	slices.SortFunc(thread.Missing, func(a, b nostr.EventPointer) int { return cmp.Compare(a.ID, b.ID) })
+
+	return thread
+}
+
+// compareNodes sorts missing events first, then from oldest to newest.
+func compareNodes(a, b *Node) int {
+	switch {
+	case a.Event == nil && b.Event == nil:
+		return cmp.Compare(a.ID, b.ID)
+	case a.Event == nil:
+		return -1
+	case b.Event == nil:
+		return 1
+	}
+	if c := cmp.Compare(a.Event.CreatedAt, b.Event.CreatedAt); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// Walk visits all nodes depth-first, parents before their replies, stopping when fn returns false.
+func (thread *Thread) Walk(fn func(node *Node, depth int) bool) {
+	var walk func(node *Node, depth int) bool
+	walk = func(node *Node, depth int) bool {
+		if !fn(node, depth) {
+			return false
+		}
+		for _, reply := range node.Replies {
+			if !walk(reply, depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(thread.Root, 0)
+}
+
+type options struct {
+	maxRounds int
+	kinds     []int
+}
+
+// Option is a function that modifies the options.
+type Option func(*options)
+
+// WithMaxRounds limits how many times FetchThread goes back to look for more replies or missing parents,
+// which is 5 by default.
+func WithMaxRounds(n int) Option {
+	return func(o *options) {
+		o.maxRounds = n
+	}
+}
+
+// WithKinds sets the kinds of the replies FetchThread looks for, which are text notes and comments by
+// default.
+func WithKinds(kinds ...int) Option {
+	return func(o *options) {
+		o.kinds = kinds
+	}
+}
+
+func getOptions(opts []Option) options {
+	o := options{
+		maxRounds: 5,
+		kinds:     []int{nostr.KindTextNote, nostr.KindComment},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type fetcher func(ctx context.Context, filter nostr.Filter, relays []string) ([]*nostr.Event, error)
+
+// FetchThread fetches the root, its replies, replies to these replies and missing parents from the store
+// and builds the thread with them.
+func FetchThread(ctx context.Context, s nostr.RelayStore, root nostr.EventPointer, opts ...Option) (*Thread, error) {
+	return fetchThread(ctx, root, getOptions(opts), func(ctx context.Context, filter nostr.Filter, _ []string) ([]*nostr.Event, error) {
+		return s.QuerySync(ctx, filter)
+	})
+}
+
+// FetchThreadFromPool is like FetchThread, but queries the given relays together with the relay hints
+// from the root pointer and from the tags that point to missing parents. Relays that fail are skipped,
+// an error is only returned when none of them could be queried.
+func FetchThreadFromPool(ctx context.Context, pool *nostr.SimplePool, relays []string, root nostr.EventPointer, opts ...Option) (*Thread, error) {
+	return fetchThread(ctx, root, getOptions(opts), func(ctx context.Context, filter nostr.Filter, hints []string) ([]*nostr.Event, error) {
+		urls := slices.Clone(relays)
+		for _, hint := range hints {
+			if url := nostr.NormalizeURL(hint); url != "" && !slices.Contains(urls, url) {
+				urls = append(urls, url)
+			}
+		}
+		if len(urls) == 0 {
+			return nil, errors.New("no relays to query")
+		}
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		var events []*nostr.Event
+		errs := make([]error, 0, len(urls))
+		for _, url := range urls {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := queryRelay(ctx, pool, url, filter)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", url, err))
+					return
+				}
+				events = append(events, res...)
+			}()
+		}
+		wg.Wait()
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(errs) == len(urls) {
+			return nil, fmt.Errorf("failed to query relays: %w", errors.Join(errs...))
+		}
+		return events, nil
+	})
+}
+
+func queryRelay(ctx context.Context, pool *nostr.SimplePool, url string, filter nostr.Filter) ([]*nostr.Event, error) {
+	relay, err := pool.EnsureRelay(url)
+	if err != nil {
+		return nil, err
+	}
+	return relay.QuerySync(ctx, filter)
+}
+
+func fetchThread(ctx context.Context, root nostr.EventPointer, o options, fetch fetcher) (*Thread, error) {
+	events := make(map[string]*nostr.Event)
+	add := func(res []*nostr.Event) (added bool) {
+		for _, evt := range res {
+			if _, ok := events[evt.ID]; !ok {
+				events[evt.ID] = evt
+				added = true
+			}
+		}
+		return added
+	}
+
+	res, err := fetch(ctx, nostr.Filter{IDs: []string{root.ID}}, root.Relays)
+	if err != nil {
+		return nil, err
+	}
+	add(res)
+
+	searched := make(map[string]bool)  // ids we looked for replies to
+	requested := make(map[string]bool) // missing ids we tried to fetch
+	for range o.maxRounds {
+		// replies to everything we have and haven't searched for yet
+		var toSearch []string
+		if !searched[root.ID] {
+			toSearch = append(toSearch, root.ID)
+		}
+		for id := range events {
+			if !searched[id] && id != root.ID {
+				toSearch = append(toSearch, id)
+			}
+		}
+		slices.Sort(toSearch)
+
+		added := false
+		if len(toSearch) > 0 {
+			// any of these ids
+			tags := nostr.TagMap{}
+			for i := range toSearch {
+				tags.Append("e", &toSearch[i])
+			}
+			res, err := fetch(ctx, nostr.Filter{Kinds: o.kinds, Tags: tags}, root.Relays)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range toSearch {
+				searched[id] = true
+			}
+			added = add(res)
+		}
+
+		// and parents that are referenced but we don't have
+		thread := BuildThread(root, slices.Collect(maps.Values(events)))
+		var ids, hints []string
+		for _, ptr := range thread.Missing {
+			if !requested[ptr.ID] {
+				ids = append(ids, ptr.ID)
+				hints = append(hints, ptr.Relays...)
+				requested[ptr.ID] = true
+			}
+		}
+		if len(ids) > 0 {
+			res, err := fetch(ctx, nostr.Filter{IDs: ids}, hints)
+			if err != nil {
+				return nil, err
+			}
+			added = add(res) || added
+		}
+
+		if !added {
+			break
+		}
+	}
+
+	return BuildThread(root, slices.Collect(maps.Values(events))), nil
+}
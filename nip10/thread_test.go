@@ -0,0 +1,123 @@
+package nip10
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/test_common"
+	"github.com/stretchr/testify/require"
+)
+
+func id(n int) string { return fmt.Sprintf("%064x", n) }
+
+func note(n int, createdAt nostr.Timestamp, tags ...nostr.Tag) *nostr.Event {
+	return &nostr.Event{ID: id(n), PubKey: strings.Repeat("a", 63) + fmt.Sprint(n%10), Kind: 1, CreatedAt: createdAt, Tags: tags}
+}
+
+func TestThread(t *testing.T) {
+	root := note(1, 100)
+	comment := note(12, 800, nostr.Tag{"E", id(1)}, nostr.Tag{"e", id(2)})
+	comment.Kind = nostr.KindComment
+	reaction := note(13, 900, nostr.Tag{"e", id(1)})
+	reaction.Kind = nostr.KindReaction
+	events := &test_common.MemoryStore{Events: []*nostr.Event{
+		root,
+		// marked
+		note(2, 200, nostr.Tag{"e", id(1), "", "root"}),
+		note(3, 150, nostr.Tag{"e", id(1), "", "root"}),
+		note(4, 300, nostr.Tag{"e", id(1), "", "root"}, nostr.Tag{"e", id(2), "", "reply"}),
+		// positional, replying to something that references nothing we know
+		note(5, 400, nostr.Tag{"e", id(1)}, nostr.Tag{"e", id(9), "wss://hint.com"}),
+		// replies to a reply without the root tag, only found by searching for replies to replies
+		note(6, 500, nostr.Tag{"e", id(3), "", "reply"}),
+		// a mention, not a reply
+		note(7, 600, nostr.Tag{"e", id(1), "", "mention"}),
+		// a cycle
+		note(10, 700, nostr.Tag{"e", id(1), "", "root"}, nostr.Tag{"e", id(11), "", "reply"}),
+		note(11, 700, nostr.Tag{"e", id(1), "", "root"}, nostr.Tag{"e", id(10), "", "reply"}),
+		// a comment, not a text note
+		comment,
+		// a reaction, not a reply
+		reaction,
+	}}
+
+	thread, err := FetchThread(context.Background(), events, nostr.EventPointer{ID: id(1)})
+	require.NoError(t, err)
+
+	var got []string
+	thread.Walk(func(node *Node, depth int) bool {
+		var n int
+		fmt.Sscanf(node.ID, "%x", &n)
+		got = append(got, fmt.Sprintf("%s%d", strings.Repeat(" ", depth), n))
+		return true
+	})
+	require.Equal(t, []string{
+		"1",
+		" 9",
+		"  5",
+		" 3",
+		"  6",
+		" 2",
+		"  4",
+		"  12",
+		" 10",
+		"  11",
+	}, got)
+
+	require.Equal(t, []nostr.EventPointer{{ID: id(9), Relays: []string{"wss://hint.com"}}}, thread.Missing)
+	require.Nil(t, thread.Nodes[id(9)].Event)
+	require.Equal(t, thread.Root, thread.Nodes[id(6)].Parent.Parent)
+	require.NotContains(t, thread.Nodes, id(13))
+
+	// only text notes, and not going back for replies to replies
+	thread, err = FetchThread(context.Background(), events, nostr.EventPointer{ID: id(1)},
+		WithKinds(nostr.KindTextNote), WithMaxRounds(1))
+	require.NoError(t, err)
+	require.Contains(t, thread.Nodes, id(3))
+	require.NotContains(t, thread.Nodes, id(6))
+	require.NotContains(t, thread.Nodes, id(12))
+}
+
+type failingStore struct{ *test_common.MemoryStore }
+
+func (s failingStore) QuerySync(ctx context.Context, filter nostr.Filter) ([]*nostr.Event, error) {
+	if len(filter.Tags) > 0 {
+		return nil, errors.New("too many tags")
+	}
+	return s.MemoryStore.QuerySync(ctx, filter)
+}
+
+func TestFetchThreadErrors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := FetchThread(ctx, failingStore{&test_common.MemoryStore{Events: []*nostr.Event{note(1, 100)}}}, nostr.EventPointer{ID: id(1)})
+	require.ErrorContains(t, err, "too many tags")
+
+	pool := nostr.NewSimplePool(ctx)
+	_, err = FetchThreadFromPool(ctx, pool, []string{"ws://127.0.0.1:1"}, nostr.EventPointer{ID: id(1)})
+	require.ErrorContains(t, err, "ws://127.0.0.1:1")
+}
+
+func TestReplyTags(t *testing.T) {
+	root := note(1, 100, nostr.Tag{"p", strings.Repeat("b", 64), "wss://b.com"})
+	tags := ReplyTags(root, "wss://relay.com")
+	require.Equal(t, nostr.Tags{
+		{"e", id(1), "wss://relay.com", "root", root.PubKey},
+		{"p", root.PubKey},
+		{"p", strings.Repeat("b", 64), "wss://b.com"},
+	}, tags)
+
+	reply := note(2, 200, tags...)
+	reply.PubKey = strings.Repeat("c", 64)
+	require.Equal(t, nostr.Tags{
+		{"e", id(1), "wss://relay.com", "root", root.PubKey},
+		{"e", id(2), "", "reply", reply.PubKey},
+		{"p", reply.PubKey},
+		{"p", root.PubKey},
+		{"p", strings.Repeat("b", 64), "wss://b.com"},
+	}, ReplyTags(reply, ""))
+}
@@ -2,12 +2,15 @@ package nip13
 
 import (
 	"context"
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"math"
 	"math/bits"
 	"runtime"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	nostr "github.com/nbd-wtf/go-nostr"
 )
@@ -78,10 +81,74 @@ func Check(id string, minDifficulty int) error {
 	return nil
 }
 
+// Progress is reported periodically by DoWork when WithProgress is given.
+type Progress struct {
+	// Hashes is how many nonces were tried in this run, Elapsed is how long it has been running.
+	Hashes  uint64
+	Elapsed time.Duration
+
+	// HashRate is in hashes per second. Estimated is the expected time to find a nonce at this rate,
+	// which doesn't go down as time passes since every hash has the same chance of being the one.
+	HashRate  float64
+	Estimated time.Duration
+
+	// Nonce is such that all nonces below it were already tried: when given to WithStartNonce for
+	// the same event and difficulty the work resumes from here.
+	Nonce uint64
+}
+
+type options struct {
+	startNonce       uint64
+	progress         func(Progress)
+	progressInterval time.Duration
+	maxDifficulty    int
+	skipRelayInfo    bool
+}
+
+// Option is a function that modifies the options.
+type Option func(*options)
+
+// WithStartNonce makes DoWork start from the given nonce, usually one reported by a previous run.
+func WithStartNonce(nonce uint64) Option {
+	return func(o *options) {
+		o.startNonce = nonce
+	}
+}
+
+// WithProgress makes DoWork call fn every interval (or every second if it is zero) and once more
+// when it gives up.
+func WithProgress(interval time.Duration, fn func(Progress)) Option {
+	return func(o *options) {
+		o.progress = fn
+		o.progressInterval = interval
+	}
+}
+
+func getOptions(opts []Option) options {
+	o := options{
+		progressInterval: time.Second,
+		maxDifficulty:    40,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.progressInterval <= 0 {
+		o.progressInterval = time.Second
+	}
+	return o
+}
+
 // DoWork() performs work in multiple threads (given by runtime.NumCPU()) and returns the first
 // nonce (as a nostr.Tag) that yields the required work.
 // Returns an error if the context expires before that.
-func DoWork(ctx context.Context, event nostr.Event, targetDifficulty int) (nostr.Tag, error) {
+//
+// The event must have its PubKey set, since it is part of the ID (see MineEvent for a version that
+// takes it from a signer).
+func DoWork(ctx context.Context, event nostr.Event, targetDifficulty int, opts ...Option) (nostr.Tag, error) {
+	return doWork(ctx, event, targetDifficulty, getOptions(opts))
+}
+
+func doWork(ctx context.Context, event nostr.Event, targetDifficulty int, o options) (nostr.Tag, error) {
 	if event.PubKey == "" {
 		return nil, ErrMissingPubKey
 	}
@@ -91,12 +158,19 @@ func DoWork(ctx context.Context, event nostr.Event, targetDifficulty int) (nostr
 	nthreads := runtime.NumCPU()
 	tagCh := make(chan nostr.Tag)
 
+	// for progress reporting: the total of hashes and the next nonce each thread will try
+	var hashes atomic.Uint64
+	next := make([]atomic.Uint64, nthreads)
+
 	for i := 0; i < nthreads; i++ {
 		// we must copy the tags here otherwise only a pointer to them will be copied below
 		// and that will cause all sorts of weird races when computing the difficulty
 		event.Tags = append(nostr.Tags{}, event.Tags...)
 
-		go func(event nostr.Event, nonce uint64) {
+		nonce := o.startNonce + uint64(i)
+		next[i].Store(nonce)
+
+		go func(event nostr.Event, nonce uint64, next *atomic.Uint64) {
 			// we make a tag here and add it -- later we will just overwrite it on each iteration
 			tag := nostr.Tag{"nonce", "", strconv.Itoa(targetDifficulty)}
 			event.Tags = append(event.Tags, tag)
@@ -106,7 +180,7 @@ func DoWork(ctx context.Context, event nostr.Event, targetDifficulty int) (nostr
 				for n := 0; n < 10000; n++ {
 					tag[1] = strconv.FormatUint(nonce, 10)
 
-					if difficultyBytes(sha256.Sum256(event.Serialize())) >= targetDifficulty {
+					if difficultyBytes(event.ComputeID()) >= targetDifficulty {
 						// must select{} here otherwise a goroutine that finds a good nonce
 						// right after the first will get stuck in the ch forever
 						select {
@@ -119,6 +193,8 @@ func DoWork(ctx context.Context, event nostr.Event, targetDifficulty int) (nostr
 
 					nonce += uint64(nthreads)
 				}
+				hashes.Add(10000)
+				next.Store(nonce)
 
 				// then check if the context was canceled
 				select {
@@ -128,13 +204,78 @@ func DoWork(ctx context.Context, event nostr.Event, targetDifficulty int) (nostr
 					// otherwise keep trying
 				}
 			}
-		}(event, uint64(i))
+		}(event, nonce, &next[i])
+	}
+
+	var tick <-chan time.Time
+	if o.progress != nil {
+		ticker := time.NewTicker(o.progressInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	start := time.Now()
+	report := func() {
+		p := Progress{
+			Hashes:  hashes.Load(),
+			Elapsed: time.Since(start),
+			Nonce:   next[0].Load(),
+		}
+		for i := range next[1:] {
+			p.Nonce = min(p.Nonce, next[i+1].Load())
+		}
+		if p.Hashes > 0 {
+			p.HashRate = float64(p.Hashes) / p.Elapsed.Seconds()
+			p.Estimated = estimate(targetDifficulty, p.HashRate)
+		}
+		o.progress(p)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if o.progress != nil {
+				report()
+			}
+			return nil, ErrGenerateTimeout
+		case tag := <-tagCh:
+			return tag, nil
+		case <-tick:
+			report()
+		}
 	}
+}
+
+// estimate returns the expected time to find a hash with the given difficulty at the given rate.
+func estimate(difficulty int, hashRate float64) time.Duration {
+	seconds := math.Ldexp(1, difficulty) / hashRate
+	if seconds >= float64(math.MaxInt64/int64(time.Second)) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// MineEvent replaces the nonce tag of the event with one that yields the required work and signs it.
+// The PubKey is taken from the signer, so it can be empty.
+func MineEvent(ctx context.Context, event *nostr.Event, signer nostr.Signer, targetDifficulty int, opts ...Option) error {
+	return mineEvent(ctx, event, signer, targetDifficulty, getOptions(opts))
+}
 
-	select {
-	case <-ctx.Done():
-		return nil, ErrGenerateTimeout
-	case tag := <-tagCh:
-		return tag, nil
+func mineEvent(ctx context.Context, event *nostr.Event, signer nostr.Signer, targetDifficulty int, o options) error {
+	pubkey, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get public key: %w", err)
 	}
+	event.PubKey = pubkey
+	event.Tags = event.Tags.FilterOut([]string{"nonce"})
+
+	tag, err := doWork(ctx, *event, targetDifficulty, o)
+	if err != nil {
+		return err
+	}
+	event.Tags = append(event.Tags, tag)
+
+	if err := signer.SignEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to sign: %w", err)
+	}
+	return nil
 }
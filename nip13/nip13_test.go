@@ -9,6 +9,7 @@ import (
 	"time"
 
 	nostr "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
 	"github.com/stretchr/testify/require"
 )
 
@@ -130,6 +131,61 @@ func TestDoWorkTimeout(t *testing.T) {
 	}
 }
 
+func TestDoWorkProgressAndResume(t *testing.T) {
+	event := nostr.Event{
+		Kind:    nostr.KindTextNote,
+		Content: "It's just me mining my own business",
+		PubKey:  "a48380f4cfcc1ad5378294fcac36439770f9c878dd880ffa94bb74ea54a6f243",
+	}
+
+	// can't be found, so it runs until the timeout and reports where it stopped
+	var reports []Progress
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	_, err := DoWork(ctx, event, 256, WithProgress(50*time.Millisecond, func(p Progress) {
+		reports = append(reports, p)
+	}))
+	require.ErrorIs(t, err, ErrGenerateTimeout)
+	require.GreaterOrEqual(t, len(reports), 2)
+
+	last := reports[len(reports)-1]
+	require.NotZero(t, last.Hashes)
+	require.NotZero(t, last.HashRate)
+	require.Greater(t, last.Estimated, time.Hour)
+	for i := 1; i < len(reports); i++ {
+		require.GreaterOrEqual(t, reports[i].Nonce, reports[i-1].Nonce)
+	}
+
+	// resuming never goes back to nonces that were tried
+	tag, err := DoWork(context.Background(), event, 1, WithStartNonce(last.Nonce))
+	require.NoError(t, err)
+	nonce, err := strconv.ParseUint(tag[1], 10, 64)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, nonce, last.Nonce)
+}
+
+func TestMineEvent(t *testing.T) {
+	signer, err := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	require.NoError(t, err)
+
+	event := nostr.Event{
+		Kind:    nostr.KindTextNote,
+		Content: "no pubkey needed",
+		Tags:    nostr.Tags{{"nonce", "1", "2"}, {"t", "pow"}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, MineEvent(ctx, &event, signer, 8))
+
+	pubkey, _ := signer.GetPublicKey(ctx)
+	require.Equal(t, pubkey, event.PubKey)
+	require.Len(t, event.Tags, 2, "old nonce tag must be replaced")
+	require.Equal(t, 8, CommittedDifficulty(&event))
+	ok, err := event.CheckSignature()
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
 func BenchmarkDoWork(b *testing.B) {
 	if testing.Short() {
 		b.Skip("too consuming for short mode")
@@ -0,0 +1,133 @@
+package nip13
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+)
+
+var ErrDifficultyTooHigh = errors.New("nip13: required difficulty is above the maximum")
+
+// WithMaxDifficulty makes Publish and PublishMany fail instead of mining when more than this is required,
+// which is 40 by default.
+func WithMaxDifficulty(difficulty int) Option {
+	return func(o *options) {
+		o.maxDifficulty = difficulty
+	}
+}
+
+// WithoutRelayInfo makes Publish and PublishMany not fetch the NIP-11 document of the relays, so they
+// only mine after being rejected.
+func WithoutRelayInfo() Option {
+	return func(o *options) {
+		o.skipRelayInfo = true
+	}
+}
+
+// RequiredDifficulty reads the difficulty asked for in a "pow:" OK message from a relay, like
+// "pow: difficulty 28 required" or "pow: difficulty 25<28", which can also be given as the text of
+// the error returned by Relay.Publish. It returns false for other messages.
+func RequiredDifficulty(reason string) (int, bool) {
+	idx := strings.Index(reason, "pow:")
+	if idx == -1 {
+		return 0, false
+	}
+
+	// when there are many numbers the biggest is the required one
+	difficulty := 0
+	notDigit := func(r rune) bool { return r < '0' || r > '9' }
+	for _, field := range strings.FieldsFunc(reason[idx+4:], notDigit) {
+		if n, err := strconv.Atoi(field); err == nil && n > difficulty {
+			difficulty = n
+		}
+	}
+	return difficulty, difficulty > 0
+}
+
+// Publish publishes the event to the relay, first mining it if the relay's NIP-11 document has a
+// min_pow_difficulty and then mining it again (and signing it with signer) every time the relay
+// rejects it with a "pow:" message. It returns the event as it was last sent.
+func Publish(ctx context.Context, relay *nostr.Relay, event nostr.Event, signer nostr.Signer, opts ...Option) (nostr.Event, error) {
+	err := publish(ctx, relay.URL, &event, signer, getOptions(opts), relay.Publish)
+	return event, err
+}
+
+// PublishMany is like SimplePool.PublishMany, but mines the event as Publish does. Relays are tried in
+// order and once mined the event is sent to the next relays with the same work.
+func PublishMany(
+	ctx context.Context,
+	pool *nostr.SimplePool,
+	urls []string,
+	event nostr.Event,
+	signer nostr.Signer,
+	opts ...Option,
+) chan nostr.PublishResult {
+	o := getOptions(opts)
+	ch := make(chan nostr.PublishResult, len(urls))
+
+	go func() {
+		for _, url := range urls {
+			var relay *nostr.Relay
+			err := publish(ctx, url, &event, signer, o, func(ctx context.Context, event nostr.Event) error {
+				// this takes care of NIP-42 authentication for us
+				res := <-pool.PublishMany(ctx, []string{url}, event)
+				relay = res.Relay
+				return res.Error
+			})
+			ch <- nostr.PublishResult{Error: err, RelayURL: url, Relay: relay}
+		}
+
+		close(ch)
+	}()
+
+	return ch
+}
+
+func publish(
+	ctx context.Context,
+	url string,
+	event *nostr.Event,
+	signer nostr.Signer,
+	o options,
+	send func(context.Context, nostr.Event) error,
+) error {
+	if !o.skipRelayInfo {
+		// relays without a NIP-11 document will just tell us when we try
+		if info, err := nip11.Fetch(ctx, url); err == nil && info.Limitation != nil {
+			if err := mineUpTo(ctx, event, signer, info.Limitation.MinPowDifficulty, o); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		err := send(ctx, *event)
+		if err == nil {
+			return nil
+		}
+
+		// this ends since every time the relay must ask for more than we already did
+		required, ok := RequiredDifficulty(err.Error())
+		if !ok || required <= CommittedDifficulty(event) {
+			return err
+		}
+		if err := mineUpTo(ctx, event, signer, required, o); err != nil {
+			return err
+		}
+	}
+}
+
+func mineUpTo(ctx context.Context, event *nostr.Event, signer nostr.Signer, difficulty int, o options) error {
+	if difficulty <= 0 || (event.ID != "" && CommittedDifficulty(event) >= difficulty) {
+		return nil
+	}
+	if difficulty > o.maxDifficulty {
+		return fmt.Errorf("%w: %d > %d", ErrDifficultyTooHigh, difficulty, o.maxDifficulty)
+	}
+	return mineEvent(ctx, event, signer, difficulty, o)
+}
@@ -0,0 +1,166 @@
+package nip13
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	nostr "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestRequiredDifficulty(t *testing.T) {
+	for _, tc := range []struct {
+		reason     string
+		difficulty int
+		ok         bool
+	}{
+		{"pow: difficulty 28 required", 28, true},
+		{"msg: pow: difficulty 25<28", 28, true},
+		{"pow: not enough work", 0, false},
+		{"blocked: difficulty 28 required", 0, false},
+		{"", 0, false},
+	} {
+		difficulty, ok := RequiredDifficulty(tc.reason)
+		require.Equal(t, tc.ok, ok, tc.reason)
+		require.Equal(t, tc.difficulty, difficulty, tc.reason)
+	}
+}
+
+// powRelay accepts events with at least the given committed difficulty and rejects the others with a
+// "pow:" message. If minPow is set it is advertised in its NIP-11 document.
+type powRelay struct {
+	required int
+	minPow   int
+
+	mu       sync.Mutex
+	received []nostr.Event
+}
+
+func (pr *powRelay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Accept") == "application/nostr+json" {
+		if pr.minPow == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"limitation": map[string]any{"min_pow_difficulty": pr.minPow},
+		})
+		return
+	}
+
+	websocket.Server{
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			for {
+				var raw []json.RawMessage
+				if err := websocket.JSON.Receive(conn, &raw); err != nil {
+					return
+				}
+				var evt nostr.Event
+				if len(raw) < 2 || json.Unmarshal(raw[1], &evt) != nil {
+					continue
+				}
+
+				pr.mu.Lock()
+				pr.received = append(pr.received, evt)
+				pr.mu.Unlock()
+
+				if CommittedDifficulty(&evt) < pr.required {
+					websocket.JSON.Send(conn, []any{"OK", evt.ID, false, "pow: difficulty 8 required"})
+				} else {
+					websocket.JSON.Send(conn, []any{"OK", evt.ID, true, ""})
+				}
+			}
+		},
+	}.ServeHTTP(w, r)
+}
+
+func testSigner(t *testing.T) nostr.Signer {
+	signer, err := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	require.NoError(t, err)
+	return signer
+}
+
+func TestPublishMinesOnRejection(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pr := &powRelay{required: 8}
+	server := httptest.NewServer(pr)
+	defer server.Close()
+
+	signer := testSigner(t)
+	event := nostr.Event{Kind: nostr.KindTextNote, CreatedAt: nostr.Now(), Content: "mine me"}
+	require.NoError(t, signer.SignEvent(ctx, &event))
+
+	relay, err := nostr.RelayConnect(ctx, server.URL)
+	require.NoError(t, err)
+	defer relay.Close()
+
+	published, err := Publish(ctx, relay, event, signer)
+	require.NoError(t, err)
+	require.NotEqual(t, event.ID, published.ID)
+	require.GreaterOrEqual(t, CommittedDifficulty(&published), 8)
+	require.True(t, published.CheckID())
+	ok, err := published.CheckSignature()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	require.Len(t, pr.received, 2)
+	require.Equal(t, event.ID, pr.received[0].ID)
+	require.Equal(t, published.ID, pr.received[1].ID)
+}
+
+func TestPublishUsesRelayInfo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pr := &powRelay{required: 8, minPow: 8}
+	server := httptest.NewServer(pr)
+	defer server.Close()
+
+	signer := testSigner(t)
+	event := nostr.Event{Kind: nostr.KindTextNote, CreatedAt: nostr.Now(), Content: "mine me first"}
+	require.NoError(t, signer.SignEvent(ctx, &event))
+
+	pool := nostr.NewSimplePool(ctx)
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	for res := range PublishMany(ctx, pool, []string{url}, event, signer) {
+		require.NoError(t, res.Error)
+	}
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	require.Len(t, pr.received, 1, "should have mined before sending")
+	require.GreaterOrEqual(t, CommittedDifficulty(&pr.received[0]), 8)
+}
+
+func TestPublishMaxDifficulty(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pr := &powRelay{required: 8}
+	server := httptest.NewServer(pr)
+	defer server.Close()
+
+	signer := testSigner(t)
+	event := nostr.Event{Kind: nostr.KindTextNote, CreatedAt: nostr.Now(), Content: "too hard"}
+	require.NoError(t, signer.SignEvent(ctx, &event))
+
+	relay, err := nostr.RelayConnect(ctx, server.URL)
+	require.NoError(t, err)
+	defer relay.Close()
+
+	_, err = Publish(ctx, relay, event, signer, WithMaxDifficulty(4), WithoutRelayInfo())
+	require.ErrorIs(t, err, ErrDifficultyTooHigh)
+}
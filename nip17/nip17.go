@@ -3,12 +3,19 @@ package nip17
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip59"
 )
 
+// ExpirationTag returns a NIP-40 "expiration" tag for messages that should disappear after ttl.
+func ExpirationTag(ttl time.Duration) nostr.Tag {
+	return nostr.Tag{"expiration", strconv.FormatInt(int64(nostr.Now())+int64(ttl.Seconds()), 10)}
+}
+
 func GetDMRelays(ctx context.Context, pubkey string, pool *nostr.SimplePool, relaysToQuery []string) []string {
 	ie := pool.QuerySingle(ctx, relaysToQuery, nostr.Filter{
 		Authors: []string{pubkey},
@@ -87,6 +94,9 @@ func PublishMessage(
 	return sendErr
 }
 
+// PrepareMessage creates the gift-wrapped direct message, one for us and one for the recipient.
+// For disappearing messages include an "expiration" tag in tags (see ExpirationTag): it is kept in the
+// message and copied to both gift-wraps, so relays can delete them once they expire.
 func PrepareMessage(
 	ctx context.Context,
 	content string,
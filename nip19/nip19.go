@@ -4,13 +4,34 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 
 	"github.com/btcsuite/btcd/btcutil/bech32"
 	"github.com/nbd-wtf/go-nostr"
 )
 
+var (
+	ErrUnknownPrefix = errors.New("unknown prefix")
+	ErrWrongPrefix   = errors.New("unexpected prefix")
+	ErrMissingTLV    = errors.New("missing required TLV")
+)
+
+// Decode decodes any NIP-19 code. value is a hex string for npub, nsec and note, a relay URL for the
+// deprecated nrelay, and a nostr.ProfilePointer, nostr.EventPointer or nostr.EntityPointer for the others.
+//
+// Unknown TLV entries are ignored, but malformed ones cause a *TLVError.
 func Decode(bech32string string) (prefix string, value any, err error) {
+	return decode(bech32string, false)
+}
+
+// DecodeStrict is like Decode, but also fails on unknown TLV types, on repeated TLV entries that can only
+// appear once and on non-zero padding.
+func DecodeStrict(bech32string string) (prefix string, value any, err error) {
+	return decode(bech32string, true)
+}
+
+func decode(bech32string string, strict bool) (prefix string, value any, err error) {
 	prefix, bits5, err := bech32.DecodeNoLimit(bech32string)
 	if err != nil {
 		return "", nil, err
@@ -20,6 +41,11 @@ func Decode(bech32string string) (prefix string, value any, err error) {
 	if err != nil {
 		return prefix, nil, fmt.Errorf("failed to translate data into 8 bits: %s", err.Error())
 	}
+	if strict && len(bits5) > 0 {
+		if extra := len(bits5) * 5 % 8; bits5[len(bits5)-1]&(1<<extra-1) != 0 {
+			return prefix, nil, fmt.Errorf("non-zero padding bits")
+		}
+	}
 
 	switch prefix {
 	case "npub", "nsec", "note":
@@ -30,105 +56,151 @@ func Decode(bech32string string) (prefix string, value any, err error) {
 		return prefix, hex.EncodeToString(data[0:32]), nil
 	case "nprofile":
 		var result nostr.ProfilePointer
-		curr := 0
-		for {
-			t, v := readTLVEntry(data[curr:])
-			if v == nil {
-				// end here
-				if result.PublicKey == "" {
-					return prefix, result, fmt.Errorf("no pubkey found for nprofile")
-				}
-
-				return prefix, result, nil
-			}
-
+		err := readTLVEntries(prefix, data, strict, func(t uint8, v []byte) error {
 			switch t {
 			case TLVDefault:
 				if len(v) != 32 {
-					return prefix, nil, fmt.Errorf("pubkey should be 32 bytes (%d)", len(v))
+					return fmt.Errorf("%w: pubkey should be 32 bytes (%d)", ErrTLVInvalidLength, len(v))
 				}
 				result.PublicKey = hex.EncodeToString(v)
 			case TLVRelay:
 				result.Relays = append(result.Relays, string(v))
 			default:
-				// ignore
+				return ErrTLVUnknownType
 			}
-
-			curr = curr + 2 + len(v)
+			return nil
+		})
+		if err != nil {
+			return prefix, nil, err
+		}
+		if result.PublicKey == "" {
+			return prefix, result, fmt.Errorf("%w: no pubkey found for nprofile", ErrMissingTLV)
 		}
+		return prefix, result, nil
 	case "nevent":
 		var result nostr.EventPointer
-		curr := 0
-		for {
-			t, v := readTLVEntry(data[curr:])
-			if v == nil {
-				// end here
-				if result.ID == "" {
-					return prefix, result, fmt.Errorf("no id found for nevent")
-				}
-
-				return prefix, result, nil
-			}
-
+		err := readTLVEntries(prefix, data, strict, func(t uint8, v []byte) error {
 			switch t {
 			case TLVDefault:
 				if len(v) != 32 {
-					return prefix, nil, fmt.Errorf("id should be 32 bytes (%d)", len(v))
+					return fmt.Errorf("%w: id should be 32 bytes (%d)", ErrTLVInvalidLength, len(v))
 				}
 				result.ID = hex.EncodeToString(v)
 			case TLVRelay:
 				result.Relays = append(result.Relays, string(v))
 			case TLVAuthor:
 				if len(v) != 32 {
-					return prefix, nil, fmt.Errorf("author should be 32 bytes (%d)", len(v))
+					return fmt.Errorf("%w: author should be 32 bytes (%d)", ErrTLVInvalidLength, len(v))
 				}
 				result.Author = hex.EncodeToString(v)
 			case TLVKind:
 				if len(v) != 4 {
-					return prefix, nil, fmt.Errorf("invalid uint32 value for integer (%v)", v)
+					return fmt.Errorf("%w: kind should be 4 bytes (%d)", ErrTLVInvalidLength, len(v))
 				}
 				result.Kind = int(binary.BigEndian.Uint32(v))
 			default:
-				// ignore
+				return ErrTLVUnknownType
 			}
-
-			curr = curr + 2 + len(v)
+			return nil
+		})
+		if err != nil {
+			return prefix, nil, err
+		}
+		if result.ID == "" {
+			return prefix, result, fmt.Errorf("%w: no id found for nevent", ErrMissingTLV)
 		}
+		return prefix, result, nil
 	case "naddr":
 		var result nostr.EntityPointer
-		curr := 0
-		for {
-			t, v := readTLVEntry(data[curr:])
-			if v == nil {
-				// end here
-				if result.Kind == 0 || result.Identifier == "" || result.PublicKey == "" {
-					return prefix, result, fmt.Errorf("incomplete naddr")
-				}
-
-				return prefix, result, nil
-			}
-
+		var hasIdentifier, hasKind bool
+		err := readTLVEntries(prefix, data, strict, func(t uint8, v []byte) error {
 			switch t {
 			case TLVDefault:
 				result.Identifier = string(v)
+				hasIdentifier = true
 			case TLVRelay:
 				result.Relays = append(result.Relays, string(v))
 			case TLVAuthor:
 				if len(v) != 32 {
-					return prefix, nil, fmt.Errorf("author should be 32 bytes (%d)", len(v))
+					return fmt.Errorf("%w: author should be 32 bytes (%d)", ErrTLVInvalidLength, len(v))
 				}
 				result.PublicKey = hex.EncodeToString(v)
 			case TLVKind:
+				if len(v) != 4 {
+					return fmt.Errorf("%w: kind should be 4 bytes (%d)", ErrTLVInvalidLength, len(v))
+				}
 				result.Kind = int(binary.BigEndian.Uint32(v))
+				hasKind = true
 			default:
-				// ignore
+				return ErrTLVUnknownType
 			}
-
-			curr = curr + 2 + len(v)
+			return nil
+		})
+		if err != nil {
+			return prefix, nil, err
+		}
+		if !hasKind || !hasIdentifier || result.PublicKey == "" {
+			return prefix, result, fmt.Errorf("%w: incomplete naddr", ErrMissingTLV)
+		}
+		return prefix, result, nil
+	case "nrelay":
+		// deprecated, but still found in the wild
+		var relay string
+		err := readTLVEntries(prefix, data, strict, func(t uint8, v []byte) error {
+			switch t {
+			case TLVDefault:
+				relay = string(v)
+			default:
+				return ErrTLVUnknownType
+			}
+			return nil
+		})
+		if err != nil {
+			return prefix, nil, err
 		}
+		if relay == "" {
+			return prefix, relay, fmt.Errorf("%w: no relay found for nrelay", ErrMissingTLV)
+		}
+		return prefix, relay, nil
+	}
+
+	return prefix, data, fmt.Errorf("%w: %s", ErrUnknownPrefix, prefix)
+}
+
+func decodeAs[V any](bech32string string, expectedPrefix string) (V, error) {
+	var zero V
+	prefix, value, err := Decode(bech32string)
+	if err != nil {
+		return zero, err
 	}
+	if prefix != expectedPrefix {
+		return zero, fmt.Errorf("%w: expected %s, got %s", ErrWrongPrefix, expectedPrefix, prefix)
+	}
+	return value.(V), nil
+}
+
+// DecodeNpub decodes an npub into a hex public key.
+func DecodeNpub(npub string) (string, error) { return decodeAs[string](npub, "npub") }
+
+// DecodeNsec decodes an nsec into a hex private key.
+func DecodeNsec(nsec string) (string, error) { return decodeAs[string](nsec, "nsec") }
 
-	return prefix, data, fmt.Errorf("unknown tag %s", prefix)
+// DecodeNote decodes a note into a hex event id.
+func DecodeNote(note string) (string, error) { return decodeAs[string](note, "note") }
+
+// DecodeNprofile decodes an nprofile.
+func DecodeNprofile(nprofile string) (nostr.ProfilePointer, error) {
+	return decodeAs[nostr.ProfilePointer](nprofile, "nprofile")
+}
+
+// DecodeNevent decodes an nevent.
+func DecodeNevent(nevent string) (nostr.EventPointer, error) {
+	return decodeAs[nostr.EventPointer](nevent, "nevent")
+}
+
+// DecodeNaddr decodes an naddr.
+func DecodeNaddr(naddr string) (nostr.EntityPointer, error) {
+	return decodeAs[nostr.EntityPointer](naddr, "naddr")
 }
 
 func EncodePrivateKey(privateKeyHex string) (string, error) {
@@ -176,13 +248,15 @@ func EncodeNote(eventIDHex string) (string, error) {
 func EncodeProfile(publicKeyHex string, relays []string) (string, error) {
 	buf := &bytes.Buffer{}
 	pubkey, err := hex.DecodeString(publicKeyHex)
-	if err != nil {
+	if err != nil || len(pubkey) != 32 {
 		return "", fmt.Errorf("invalid pubkey '%s': %w", publicKeyHex, err)
 	}
 	writeTLVEntry(buf, TLVDefault, pubkey)
 
 	for _, url := range relays {
-		writeTLVEntry(buf, TLVRelay, []byte(url))
+		if err := writeTLVEntry(buf, TLVRelay, []byte(url)); err != nil {
+			return "", err
+		}
 	}
 
 	bits5, err := bech32.ConvertBits(buf.Bytes(), 8, 5, true)
@@ -194,6 +268,10 @@ func EncodeProfile(publicKeyHex string, relays []string) (string, error) {
 }
 
 func EncodeEvent(eventIDHex string, relays []string, author string) (string, error) {
+	return encodeEvent(eventIDHex, relays, author, 0)
+}
+
+func encodeEvent(eventIDHex string, relays []string, author string, kind int) (string, error) {
 	buf := &bytes.Buffer{}
 	id, err := hex.DecodeString(eventIDHex)
 	if err != nil || len(id) != 32 {
@@ -202,13 +280,21 @@ func EncodeEvent(eventIDHex string, relays []string, author string) (string, err
 	writeTLVEntry(buf, TLVDefault, id)
 
 	for _, url := range relays {
-		writeTLVEntry(buf, TLVRelay, []byte(url))
+		if err := writeTLVEntry(buf, TLVRelay, []byte(url)); err != nil {
+			return "", err
+		}
 	}
 
 	if pubkey, _ := hex.DecodeString(author); len(pubkey) == 32 {
 		writeTLVEntry(buf, TLVAuthor, pubkey)
 	}
 
+	if kind != 0 {
+		kindBytes := make([]byte, 4)
+		binary.BigEndian.PutUint32(kindBytes, uint32(kind))
+		writeTLVEntry(buf, TLVKind, kindBytes)
+	}
+
 	bits5, err := bech32.ConvertBits(buf.Bytes(), 8, 5, true)
 	if err != nil {
 		return "", fmt.Errorf("failed to convert bits: %w", err)
@@ -220,15 +306,19 @@ func EncodeEvent(eventIDHex string, relays []string, author string) (string, err
 func EncodeEntity(publicKey string, kind int, identifier string, relays []string) (string, error) {
 	buf := &bytes.Buffer{}
 
-	writeTLVEntry(buf, TLVDefault, []byte(identifier))
+	if err := writeTLVEntry(buf, TLVDefault, []byte(identifier)); err != nil {
+		return "", err
+	}
 
 	for _, url := range relays {
-		writeTLVEntry(buf, TLVRelay, []byte(url))
+		if err := writeTLVEntry(buf, TLVRelay, []byte(url)); err != nil {
+			return "", err
+		}
 	}
 
 	pubkey, err := hex.DecodeString(publicKey)
-	if err != nil {
-		return "", fmt.Errorf("invalid pubkey '%s': %w", pubkey, err)
+	if err != nil || len(pubkey) != 32 {
+		return "", fmt.Errorf("invalid pubkey '%s': %w", publicKey, err)
 	}
 	writeTLVEntry(buf, TLVAuthor, pubkey)
 
@@ -243,3 +333,24 @@ func EncodeEntity(publicKey string, kind int, identifier string, relays []string
 
 	return bech32.Encode("naddr", bits5)
 }
+
+// EncodePointer encodes a nostr.ProfilePointer, nostr.EventPointer or nostr.EntityPointer (or pointers to
+// them) as nprofile, nevent or naddr, including all their fields.
+func EncodePointer(pointer any) (string, error) {
+	switch p := pointer.(type) {
+	case nostr.ProfilePointer:
+		return EncodeProfile(p.PublicKey, p.Relays)
+	case *nostr.ProfilePointer:
+		return EncodeProfile(p.PublicKey, p.Relays)
+	case nostr.EventPointer:
+		return encodeEvent(p.ID, p.Relays, p.Author, p.Kind)
+	case *nostr.EventPointer:
+		return encodeEvent(p.ID, p.Relays, p.Author, p.Kind)
+	case nostr.EntityPointer:
+		return EncodeEntity(p.PublicKey, p.Kind, p.Identifier, p.Relays)
+	case *nostr.EntityPointer:
+		return EncodeEntity(p.PublicKey, p.Kind, p.Identifier, p.Relays)
+	default:
+		return "", fmt.Errorf("can't encode %T", pointer)
+	}
+}
@@ -1,8 +1,12 @@
 package nip19
 
 import (
+	"bytes"
+	"encoding/hex"
+	"strings"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcutil/bech32"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -145,3 +149,138 @@ func TestFailDecodeBadlyFormattedPubkey(t *testing.T) {
 	_, _, err := Decode("nevent1qqsgaj0la08u0vl2ecmlmrg4xl0vjcz647yx7jgvgzfr566ael4hmjgpp4mhxue69uhhjctzw5hx6egzgqurswpc8qurswpexq6rjvm9xp3nvcfkv56xzv35v9jnxve389snqephve3n2wf4vdsnxepcv56kxct9xyunjdf5v5cnzveexqcrsepnk6yu5r")
 	require.Error(t, err, "should fail to decode this because the author is hex as bytes garbage")
 }
+
+func encodeRaw(t testing.TB, prefix string, data []byte) string {
+	bits5, err := bech32.ConvertBits(data, 8, 5, true)
+	require.NoError(t, err)
+	code, err := bech32.Encode(prefix, bits5)
+	require.NoError(t, err)
+	return code
+}
+
+func TestDecodeStrictAndLenient(t *testing.T) {
+	pubkey, _ := hex.DecodeString("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	relay := []byte("wss://relay.com")
+
+	tlv := func(entries ...[]byte) []byte {
+		buf := &bytes.Buffer{}
+		for i := 0; i < len(entries); i += 2 {
+			writeTLVEntry(buf, entries[i][0], entries[i+1])
+		}
+		return buf.Bytes()
+	}
+
+	// unknown types are only rejected in strict mode
+	withUnknown := encodeRaw(t, "nprofile", tlv([]byte{TLVDefault}, pubkey, []byte{9}, []byte("?"), []byte{TLVRelay}, relay))
+	_, data, err := Decode(withUnknown)
+	require.NoError(t, err)
+	require.Equal(t, nostr.ProfilePointer{PublicKey: hex.EncodeToString(pubkey), Relays: []string{"wss://relay.com"}}, data)
+
+	_, _, err = DecodeStrict(withUnknown)
+	var tlvErr *TLVError
+	require.ErrorAs(t, err, &tlvErr)
+	require.Equal(t, TLVError{Prefix: "nprofile", Index: 1, Type: 9, Err: ErrTLVUnknownType}, *tlvErr)
+
+	// so are repeated entries
+	repeated := encodeRaw(t, "nprofile", tlv([]byte{TLVDefault}, pubkey, []byte{TLVRelay}, relay, []byte{TLVRelay}, relay, []byte{TLVDefault}, pubkey))
+	_, _, err = Decode(repeated)
+	require.NoError(t, err)
+	_, _, err = DecodeStrict(repeated)
+	require.ErrorIs(t, err, ErrTLVDuplicate)
+
+	// but malformed entries never pass
+	truncated := encodeRaw(t, "nevent", append(tlv([]byte{TLVDefault}, pubkey), TLVRelay, 20, 'w'))
+	_, _, err = Decode(truncated)
+	require.ErrorAs(t, err, &tlvErr)
+	require.Equal(t, 1, tlvErr.Index)
+	require.ErrorIs(t, err, ErrTLVTruncated)
+
+	badKind := encodeRaw(t, "naddr", tlv([]byte{TLVDefault}, []byte("x"), []byte{TLVAuthor}, pubkey, []byte{TLVKind}, []byte{1, 2}))
+	_, _, err = Decode(badKind)
+	require.ErrorIs(t, err, ErrTLVInvalidLength)
+
+	// naddr for replaceable events have an empty identifier and may have kind 0
+	naddr, err := EncodeEntity(hex.EncodeToString(pubkey), 0, "", nil)
+	require.NoError(t, err)
+	_, data, err = DecodeStrict(naddr)
+	require.NoError(t, err)
+	require.Equal(t, nostr.EntityPointer{PublicKey: hex.EncodeToString(pubkey)}, data)
+
+	// deprecated nrelay
+	prefix, data, err := DecodeStrict(encodeRaw(t, "nrelay", tlv([]byte{TLVDefault}, relay)))
+	require.NoError(t, err)
+	require.Equal(t, "nrelay", prefix)
+	require.Equal(t, "wss://relay.com", data)
+
+	_, _, err = Decode(encodeRaw(t, "nthing", pubkey))
+	require.ErrorIs(t, err, ErrUnknownPrefix)
+
+	_, err = EncodeProfile(hex.EncodeToString(pubkey), []string{strings.Repeat("x", 256)})
+	require.ErrorIs(t, err, ErrTLVTooLong)
+}
+
+func TestTypedDecode(t *testing.T) {
+	ep := nostr.EventPointer{
+		ID:     "45326f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751ac194",
+		Relays: []string{"wss://banana.com"},
+		Author: "7fa56f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751abb88",
+		Kind:   1,
+	}
+	nevent, err := EncodePointer(&ep)
+	require.NoError(t, err)
+
+	decoded, err := DecodeNevent(nevent)
+	require.NoError(t, err)
+	require.Equal(t, ep, decoded)
+
+	_, err = DecodeNaddr(nevent)
+	require.ErrorIs(t, err, ErrWrongPrefix)
+
+	pk, err := DecodeNpub("npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6")
+	require.NoError(t, err)
+	require.Equal(t, "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d", pk)
+
+	_, err = DecodeNsec("npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6")
+	require.ErrorIs(t, err, ErrWrongPrefix)
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add("nprofile", []byte{0, 32, 1, 2, 3})
+	f.Add("naddr", []byte{0, 1, 'x', 3, 4, 0, 0, 0, 1})
+	f.Add("nevent", []byte{})
+	f.Fuzz(func(t *testing.T, prefix string, data []byte) {
+		bits5, err := bech32.ConvertBits(data, 8, 5, true)
+		if err != nil {
+			return
+		}
+		code, err := bech32.Encode(prefix, bits5)
+		if err != nil {
+			return
+		}
+
+		// must never panic
+		Decode(code)
+
+		prefix, value, err := DecodeStrict(code)
+		if err != nil || prefix == "nsec" || prefix == "nrelay" {
+			return
+		}
+
+		// everything we decode strictly must encode back to the same thing
+		var again string
+		switch v := value.(type) {
+		case string:
+			if prefix == "npub" {
+				again, err = EncodePublicKey(v)
+			} else {
+				again, err = EncodeNote(v)
+			}
+		default:
+			again, err = EncodePointer(v)
+		}
+		require.NoError(t, err)
+		_, valueAgain, err := DecodeStrict(again)
+		require.NoError(t, err)
+		require.Equal(t, value, valueAgain)
+	})
+}
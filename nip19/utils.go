@@ -2,6 +2,8 @@ package nip19
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 )
 
 const (
@@ -11,20 +13,78 @@ const (
 	TLVKind    uint8 = 3
 )
 
-func readTLVEntry(data []byte) (typ uint8, value []byte) {
+var (
+	ErrTLVTruncated     = errors.New("TLV entry is truncated")
+	ErrTLVInvalidLength = errors.New("TLV entry has an invalid length")
+	ErrTLVUnknownType   = errors.New("unknown TLV type")
+	ErrTLVDuplicate     = errors.New("TLV entry can only appear once")
+	ErrTLVTooLong       = errors.New("TLV values can't be longer than 255 bytes")
+)
+
+// TLVError tells which TLV entry of a code was malformed.
+type TLVError struct {
+	Prefix string
+	// Index is the position of the entry, starting at 0.
+	Index int
+	Type  uint8
+	Err   error
+}
+
+func (e *TLVError) Error() string {
+	return fmt.Sprintf("%s: TLV entry %d (type %d): %s", e.Prefix, e.Index, e.Type, e.Err)
+}
+
+func (e *TLVError) Unwrap() error { return e.Err }
+
+// readTLVEntries calls handle for each entry in data. handle returns ErrTLVUnknownType for types it doesn't
+// know, which are ignored unless strict is set. In strict mode only relays can be repeated.
+func readTLVEntries(prefix string, data []byte, strict bool, handle func(typ uint8, value []byte) error) error {
+	var seen [256]bool
+	for i, curr := 0, 0; curr < len(data); i++ {
+		typ, v, ok := readTLVEntry(data[curr:])
+		if !ok {
+			return &TLVError{Prefix: prefix, Index: i, Type: typ, Err: ErrTLVTruncated}
+		}
+		curr += 2 + len(v)
+
+		if strict && typ != TLVRelay && seen[typ] {
+			return &TLVError{Prefix: prefix, Index: i, Type: typ, Err: ErrTLVDuplicate}
+		}
+		seen[typ] = true
+
+		if err := handle(typ, v); err != nil {
+			if err == ErrTLVUnknownType && !strict {
+				continue
+			}
+			return &TLVError{Prefix: prefix, Index: i, Type: typ, Err: err}
+		}
+	}
+	return nil
+}
+
+func readTLVEntry(data []byte) (typ uint8, value []byte, ok bool) {
 	if len(data) < 2 {
-		return 0, nil
+		if len(data) == 1 {
+			typ = data[0]
+		}
+		return typ, nil, false
 	}
 
 	typ = data[0]
 	length := int(data[1])
-	value = data[2 : 2+length]
-	return
+	if len(data) < 2+length {
+		return typ, nil, false
+	}
+	return typ, data[2 : 2+length], true
 }
 
-func writeTLVEntry(buf *bytes.Buffer, typ uint8, value []byte) {
+func writeTLVEntry(buf *bytes.Buffer, typ uint8, value []byte) error {
 	length := len(value)
+	if length > 255 {
+		return fmt.Errorf("%w (type %d has %d)", ErrTLVTooLong, typ, length)
+	}
 	buf.WriteByte(typ)
 	buf.WriteByte(uint8(length))
 	buf.Write(value)
+	return nil
 }
@@ -0,0 +1,83 @@
+package nip21
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+const Scheme = "nostr:"
+
+var (
+	ErrNotNostrURI = errors.New("not a nostr: URI")
+	ErrNsecURI     = errors.New("nsec can't be used in nostr: URIs")
+)
+
+// IsNostrURI checks if s starts with the "nostr:" scheme, in any case.
+func IsNostrURI(s string) bool {
+	return len(s) >= len(Scheme) && strings.EqualFold(s[0:len(Scheme)], Scheme)
+}
+
+// Parse parses a "nostr:" URI into a nostr.ProfilePointer (from npub and nprofile), a nostr.EventPointer
+// (from note and nevent) or a nostr.EntityPointer (from naddr).
+func Parse(uri string) (pointer any, err error) {
+	if !IsNostrURI(uri) {
+		return nil, ErrNotNostrURI
+	}
+
+	prefix, data, err := nip19.Decode(uri[len(Scheme):])
+	if err != nil {
+		return nil, err
+	}
+
+	switch prefix {
+	case "npub":
+		return nostr.ProfilePointer{PublicKey: data.(string)}, nil
+	case "note":
+		return nostr.EventPointer{ID: data.(string)}, nil
+	case "nprofile", "nevent", "naddr":
+		return data, nil
+	case "nsec":
+		return nil, ErrNsecURI
+	default:
+		return nil, fmt.Errorf("%w: %s", nip19.ErrUnknownPrefix, prefix)
+	}
+}
+
+// Format turns a pointer into a "nostr:" URI. Pointers that only have a pubkey or an id become npub or
+// note, everything else becomes nprofile, nevent or naddr, so Parse gives back the same pointer.
+func Format(pointer any) (string, error) {
+	var code string
+	var err error
+
+	switch p := pointer.(type) {
+	case *nostr.ProfilePointer:
+		return Format(*p)
+	case *nostr.EventPointer:
+		return Format(*p)
+	case *nostr.EntityPointer:
+		return Format(*p)
+	case nostr.ProfilePointer:
+		if len(p.Relays) == 0 {
+			code, err = nip19.EncodePublicKey(p.PublicKey)
+		} else {
+			code, err = nip19.EncodePointer(p)
+		}
+	case nostr.EventPointer:
+		if len(p.Relays) == 0 && p.Author == "" && p.Kind == 0 {
+			code, err = nip19.EncodeNote(p.ID)
+		} else {
+			code, err = nip19.EncodePointer(p)
+		}
+	default:
+		code, err = nip19.EncodePointer(p)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return Scheme + code, nil
+}
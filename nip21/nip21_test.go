@@ -0,0 +1,72 @@
+package nip21
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	pk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	id := "45326f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751ac194"
+
+	for _, pointer := range []any{
+		nostr.ProfilePointer{PublicKey: pk},
+		nostr.ProfilePointer{PublicKey: pk, Relays: []string{"wss://r.x.com", "wss://djbas.sadkb.com"}},
+		nostr.EventPointer{ID: id},
+		nostr.EventPointer{ID: id, Author: pk},
+		nostr.EventPointer{ID: id, Relays: []string{"wss://banana.com"}, Author: pk, Kind: 1},
+		nostr.EntityPointer{PublicKey: pk, Kind: 30023, Identifier: "banana", Relays: []string{"wss://banana.com"}},
+		nostr.EntityPointer{PublicKey: pk, Kind: 10002},
+	} {
+		uri, err := Format(pointer)
+		require.NoError(t, err)
+		require.True(t, IsNostrURI(uri))
+
+		back, err := Parse(uri)
+		require.NoError(t, err)
+		require.Equal(t, pointer, back)
+	}
+
+	uri, _ := Format(&nostr.ProfilePointer{PublicKey: pk})
+	require.Equal(t, "nostr:npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6", uri)
+
+	back, err := Parse("NOSTR:npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6")
+	require.NoError(t, err)
+	require.Equal(t, nostr.ProfilePointer{PublicKey: pk}, back)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse("npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6")
+	require.ErrorIs(t, err, ErrNotNostrURI)
+
+	nsec, _ := nip19.EncodePrivateKey("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	_, err = Parse(Scheme + nsec)
+	require.ErrorIs(t, err, ErrNsecURI)
+
+	_, err = Parse("nostr:npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w4")
+	require.Error(t, err)
+
+	_, err = Format("npub1...")
+	require.Error(t, err)
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("nostr:npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6")
+	f.Add("nostr:nevent1qqsy2vn0t45k92c78n2zfe6ccvqzhpn977cd3h8wnl579zxhw5dvr9qpzpmhxue69uhkyctwv9hxztnrdaksygrl54h466tz4v0re4pyuavvxqptsejl0vxcmnhfl60z3rth2x4m3q04ndyp")
+	f.Add("nostr:naddr1qq98yetxv4ex2mnrv4esygrl54h466tz4v0re4pyuavvxqptsejl0vxcmnhfl60z3rth2xkpjspsgqqqw4rsf34vl5")
+	f.Fuzz(func(t *testing.T, uri string) {
+		pointer, err := Parse(uri)
+		if err != nil {
+			return
+		}
+
+		again, err := Format(pointer)
+		require.NoError(t, err)
+		back, err := Parse(again)
+		require.NoError(t, err)
+		require.Equal(t, pointer, back)
+	})
+}
@@ -0,0 +1,240 @@
+package nip22
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var (
+	ErrNotComment    = errors.New("event is not a comment")
+	ErrMissingRoot   = errors.New("comment has no root scope")
+	ErrMissingParent = errors.New("comment has no parent scope")
+	ErrEmptyRoot     = errors.New("root has no event id, address or external id")
+)
+
+// Reference is what a comment points to, either as its root or as its parent: an event, an address or
+// an external identifier (NIP-73). For addressable events both EventID and Address can be set.
+type Reference struct {
+	EventID  string
+	Address  *nostr.Address
+	External string
+
+	// Relay is a hint for where the event can be found, or a URL hint for external identifiers.
+	Relay string
+
+	// Kind is the event kind as a decimal string or, for external identifiers, a NIP-73 kind like "web"
+	// or "podcast:item:guid".
+	Kind string
+
+	// Author is the pubkey of the event author, empty for external identifiers.
+	Author string
+}
+
+// ReferenceTo returns a Reference to the given event, which can be seen at relay.
+func ReferenceTo(evt *nostr.Event, relay string) Reference {
+	ref := Reference{
+		EventID: evt.ID,
+		Relay:   relay,
+		Kind:    strconv.Itoa(evt.Kind),
+		Author:  evt.PubKey,
+	}
+	if addr, ok := evt.Address(); ok {
+		ref.Address = &addr
+	}
+	return ref
+}
+
+// AddressReference returns a Reference to the latest version of an addressable or replaceable event.
+func AddressReference(addr nostr.Address, relay string) Reference {
+	return Reference{
+		Address: &addr,
+		Relay:   relay,
+		Kind:    strconv.Itoa(addr.Kind),
+		Author:  addr.PubKey,
+	}
+}
+
+// ExternalReference returns a Reference to something outside of nostr, like a URL (with kind "web").
+func ExternalReference(id string, kind string, hint string) Reference {
+	return Reference{External: id, Kind: kind, Relay: hint}
+}
+
+// appendTags adds the tags for this reference, with uppercase names for the root scope and lowercase
+// for the parent scope.
+func (ref Reference) appendTags(tags nostr.Tags, root bool) nostr.Tags {
+	name := func(s string) string {
+		if root {
+			return strings.ToUpper(s)
+		}
+		return s
+	}
+
+	if ref.Address != nil {
+		tags = append(tags, trimTag(nostr.Tag{name("a"), ref.Address.String(), ref.Relay}))
+	}
+	if ref.EventID != "" {
+		tags = append(tags, trimTag(nostr.Tag{name("e"), ref.EventID, ref.Relay, ref.Author}))
+	}
+	if ref.External != "" {
+		tags = append(tags, trimTag(nostr.Tag{name("i"), ref.External, ref.Relay}))
+	}
+	if ref.Kind != "" {
+		tags = append(tags, nostr.Tag{name("k"), ref.Kind})
+	}
+	if ref.Author != "" {
+		tags = append(tags, nostr.Tag{name("p"), ref.Author})
+	}
+
+	return tags
+}
+
+func trimTag(tag nostr.Tag) nostr.Tag {
+	for len(tag) > 2 && tag[len(tag)-1] == "" {
+		tag = tag[:len(tag)-1]
+	}
+	return tag
+}
+
+func parseReference(tags nostr.Tags, root bool) (Reference, bool) {
+	name := func(s string) string {
+		if root {
+			return strings.ToUpper(s)
+		}
+		return s
+	}
+	value := func(tag nostr.Tag, i int) string {
+		if len(tag) > i {
+			return tag[i]
+		}
+		return ""
+	}
+
+	var ref Reference
+	for _, tag := range tags {
+		if len(tag) < 2 || tag[1] == "" {
+			continue
+		}
+
+		switch tag[0] {
+		case name("e"):
+			if ref.EventID == "" {
+				ref.EventID = tag[1]
+				ref.Relay = value(tag, 2)
+				if author := value(tag, 3); author != "" {
+					ref.Author = author
+				}
+			}
+		case name("a"):
+			if ref.Address == nil {
+				if addr, err := nostr.ParseAddress(tag[1]); err == nil {
+					ref.Address = &addr
+					if ref.Relay == "" {
+						ref.Relay = value(tag, 2)
+					}
+				}
+			}
+		case name("i"):
+			if ref.External == "" {
+				ref.External = tag[1]
+				ref.Relay = value(tag, 2)
+			}
+		case name("k"):
+			if ref.Kind == "" {
+				ref.Kind = tag[1]
+			}
+		case name("p"):
+			if ref.Author == "" {
+				ref.Author = tag[1]
+			}
+		}
+	}
+
+	return ref, ref.EventID != "" || ref.Address != nil || ref.External != ""
+}
+
+// Comment is a kind 1111 event with its root and parent scopes.
+type Comment struct {
+	nostr.Event
+
+	Root   Reference
+	Parent Reference
+}
+
+// ParseComment reads the root and parent scopes of a comment.
+func ParseComment(event nostr.Event) (Comment, error) {
+	comment := Comment{Event: event}
+	if event.Kind != nostr.KindComment {
+		return comment, ErrNotComment
+	}
+
+	var ok bool
+	if comment.Root, ok = parseReference(event.Tags, true); !ok {
+		return comment, ErrMissingRoot
+	}
+	if comment.Parent, ok = parseReference(event.Tags, false); !ok {
+		return comment, ErrMissingParent
+	}
+	return comment, nil
+}
+
+// IsTopLevel tells if the comment is directly on the root, not a reply to another comment.
+func (c Comment) IsTopLevel() bool {
+	return c.Parent.Kind != strconv.Itoa(nostr.KindComment)
+}
+
+// MakeComment creates an unsigned comment on parent, in the thread of root. For comments directly on the
+// root, parent is the same as root.
+func MakeComment(root Reference, parent Reference, content string) nostr.Event {
+	tags := make(nostr.Tags, 0, 10)
+	tags = root.appendTags(tags, true)
+	tags = parent.appendTags(tags, false)
+
+	return nostr.Event{
+		Kind:      nostr.KindComment,
+		CreatedAt: nostr.Now(),
+		Tags:      tags,
+		Content:   content,
+	}
+}
+
+// MakeReply creates an unsigned comment replying to another comment, keeping its root. relay is where
+// the comment being replied to can be found.
+func MakeReply(comment Comment, relay string, content string) nostr.Event {
+	return MakeComment(comment.Root, ReferenceTo(&comment.Event, relay), content)
+}
+
+// CommentsFilter returns a filter for all comments in the thread of root, at any depth.
+// When the root has an address, comments on any of its versions are included. It fails with ErrEmptyRoot
+// if root doesn't point to anything, since the filter would match all comments.
+func CommentsFilter(root Reference) (nostr.Filter, error) {
+	filter := nostr.Filter{Kinds: []int{nostr.KindComment}}
+	switch {
+	case root.Address != nil:
+		filter.Tags = nostr.TagMap{}.SetLiterals("A", root.Address.String())
+	case root.EventID != "":
+		filter.Tags = nostr.TagMap{}.SetLiterals("E", root.EventID)
+	case root.External != "":
+		filter.Tags = nostr.TagMap{}.SetLiterals("I", root.External)
+	default:
+		return filter, ErrEmptyRoot
+	}
+	return filter, nil
+}
+
+// EventCommentsFilter returns a filter for all comments under the event with the given id.
+func EventCommentsFilter(id string) (nostr.Filter, error) {
+	return CommentsFilter(Reference{EventID: id})
+}
+
+// AddressCommentsFilter returns a filter for all comments under the event at the given address.
+func AddressCommentsFilter(addr nostr.Address) (nostr.Filter, error) {
+	return CommentsFilter(Reference{Address: &addr})
+}
+
+// ExternalCommentsFilter returns a filter for all comments under the given external identifier.
+func ExternalCommentsFilter(id string) (nostr.Filter, error) {
+	return CommentsFilter(Reference{External: id})
+}
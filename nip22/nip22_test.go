@@ -0,0 +1,86 @@
+package nip22
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArticleComments(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	article := &nostr.Event{Kind: nostr.KindArticle, Tags: nostr.Tags{{"d", "hello"}}, Content: "# hello"}
+	require.NoError(t, article.Sign(sk))
+	addr, _ := article.Address()
+
+	root := ReferenceTo(article, "wss://relay.com")
+	top := MakeComment(root, root, "great article")
+	require.NoError(t, top.Sign(sk))
+	require.Equal(t, nostr.Tags{
+		{"A", addr.String(), "wss://relay.com"},
+		{"E", article.ID, "wss://relay.com", article.PubKey},
+		{"K", "30023"},
+		{"P", article.PubKey},
+		{"a", addr.String(), "wss://relay.com"},
+		{"e", article.ID, "wss://relay.com", article.PubKey},
+		{"k", "30023"},
+		{"p", article.PubKey},
+	}, top.Tags)
+
+	comment, err := ParseComment(top)
+	require.NoError(t, err)
+	require.Equal(t, root, comment.Root)
+	require.Equal(t, root, comment.Parent)
+	require.True(t, comment.IsTopLevel())
+
+	reply := MakeReply(comment, "wss://other.com", "thanks")
+	require.NoError(t, reply.Sign(sk))
+	parsed, err := ParseComment(reply)
+	require.NoError(t, err)
+	require.False(t, parsed.IsTopLevel())
+	require.Equal(t, root, parsed.Root)
+	require.Equal(t, Reference{EventID: top.ID, Relay: "wss://other.com", Kind: "1111", Author: top.PubKey}, parsed.Parent)
+
+	// both comments are found by the root filter, even on a new version of the article
+	filter, err := CommentsFilter(root)
+	require.NoError(t, err)
+	require.True(t, filter.Matches(&top))
+	require.True(t, filter.Matches(&reply))
+	addrFilter, err := AddressCommentsFilter(addr)
+	require.NoError(t, err)
+	require.Equal(t, filter, addrFilter)
+	eventFilter, err := EventCommentsFilter(article.ID)
+	require.NoError(t, err)
+	require.True(t, eventFilter.Matches(&reply))
+
+	// a root that points to nothing would get every comment
+	_, err = CommentsFilter(Reference{Kind: "30023"})
+	require.ErrorIs(t, err, ErrEmptyRoot)
+	_, err = EventCommentsFilter("")
+	require.ErrorIs(t, err, ErrEmptyRoot)
+}
+
+func TestExternalComments(t *testing.T) {
+	root := ExternalReference("https://example.com/post", "web", "")
+	evt := MakeComment(root, root, "nice post")
+	require.Equal(t, nostr.Tags{
+		{"I", "https://example.com/post"},
+		{"K", "web"},
+		{"i", "https://example.com/post"},
+		{"k", "web"},
+	}, evt.Tags)
+
+	comment, err := ParseComment(evt)
+	require.NoError(t, err)
+	require.Equal(t, root, comment.Root)
+	filter, err := ExternalCommentsFilter("https://example.com/post")
+	require.NoError(t, err)
+	require.True(t, filter.Matches(&evt))
+
+	_, err = ParseComment(nostr.Event{Kind: nostr.KindComment, Tags: nostr.Tags{{"e", "x"}}})
+	require.ErrorIs(t, err, ErrMissingRoot)
+	_, err = ParseComment(nostr.Event{Kind: nostr.KindComment, Tags: nostr.Tags{{"E", "x"}}})
+	require.ErrorIs(t, err, ErrMissingParent)
+	_, err = ParseComment(nostr.Event{Kind: nostr.KindTextNote})
+	require.ErrorIs(t, err, ErrNotComment)
+}
@@ -0,0 +1,88 @@
+package nip27
+
+import (
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip21"
+)
+
+// Compose is the inverse of Tokenize: it joins the segments into content and returns the tags that go
+// with it: "p" for profile mentions, "q" for event and address mentions, "t" for hashtags and "emoji"
+// for custom emojis.
+//
+// Segments are written as their Text when it is set, so the output of Tokenize gives back the same
+// content. Otherwise they are rendered from their other fields, mentions always as "nostr:" URIs.
+func Compose(segments []Segment) (content string, tags nostr.Tags, err error) {
+	var b strings.Builder
+	tags = make(nostr.Tags, 0, 4)
+
+	for _, seg := range segments {
+		text := seg.Text
+
+		switch seg.Type {
+		case Mention:
+			if !nip21.IsNostrURI(text) {
+				// legacy "#[n]" mentions would point to the wrong tags
+				text, err = nip21.Format(seg.Pointer)
+				if err != nil {
+					return "", nil, err
+				}
+			}
+			tags = appendPointerTags(tags, seg.Pointer)
+		case Hashtag:
+			if text == "" {
+				text = "#" + seg.Value
+			}
+			tags = tags.AppendUnique(nostr.Tag{"t", strings.ToLower(seg.Value)})
+		case Emoji:
+			if text == "" {
+				text = ":" + seg.Value + ":"
+			}
+			tags = tags.AppendUnique(nostr.Tag{"emoji", seg.Value, seg.EmojiURL})
+		case Code:
+			if text == "" {
+				if seg.Block {
+					text = "```" + seg.Value + "```"
+				} else {
+					text = "`" + seg.Value + "`"
+				}
+			}
+		default:
+			if text == "" {
+				text = seg.Value
+			}
+		}
+
+		b.WriteString(text)
+	}
+
+	return b.String(), tags, nil
+}
+
+func appendPointerTags(tags nostr.Tags, pointer any) nostr.Tags {
+	var tag nostr.Tag
+	switch p := pointer.(type) {
+	case nostr.ProfilePointer:
+		tag = nostr.Tag{"p", p.PublicKey, firstRelay(p.Relays)}
+	case nostr.EventPointer:
+		tag = nostr.Tag{"q", p.ID, firstRelay(p.Relays), p.Author}
+	case nostr.EntityPointer:
+		tag = nostr.Tag{"q", p.Address().String(), firstRelay(p.Relays), p.PublicKey}
+	default:
+		return tags
+	}
+
+	// no empty trailing items
+	for tag[len(tag)-1] == "" {
+		tag = tag[:len(tag)-1]
+	}
+	return tags.AppendUnique(tag)
+}
+
+func firstRelay(relays []string) string {
+	if len(relays) > 0 {
+		return relays[0]
+	}
+	return ""
+}
@@ -0,0 +1,315 @@
+package nip27
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip21"
+)
+
+type SegmentType int
+
+const (
+	Text SegmentType = iota
+	URL
+	Mention
+	Hashtag
+	Emoji
+	Lightning
+	Cashu
+	Code
+)
+
+func (t SegmentType) String() string {
+	switch t {
+	case Text:
+		return "text"
+	case URL:
+		return "url"
+	case Mention:
+		return "mention"
+	case Hashtag:
+		return "hashtag"
+	case Emoji:
+		return "emoji"
+	case Lightning:
+		return "lightning"
+	case Cashu:
+		return "cashu"
+	case Code:
+		return "code"
+	default:
+		return "segment(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
+// Segment is a piece of an event's content.
+type Segment struct {
+	Type SegmentType
+
+	// Start and End are byte offsets into the content, Text is content[Start:End].
+	Start int
+	End   int
+	Text  string
+
+	// Value depends on the type: the text itself, the URL, the hashtag without "#", the emoji shortcode
+	// without ":", the invoice or LNURL without "lightning:", the cashu token or the code without the
+	// backticks. It is empty for mentions.
+	Value string
+
+	// MediaType is "image", "video" or "audio" for URLs that look like they point to media, guessed from
+	// the file extension.
+	MediaType string
+
+	// Pointer is a nostr.ProfilePointer, nostr.EventPointer or nostr.EntityPointer for mentions.
+	Pointer any
+
+	// EmojiURL is the image for custom emojis, taken from the matching "emoji" tag.
+	EmojiURL string
+
+	// Tagged is true for hashtags that have a matching "t" tag.
+	Tagged bool
+
+	// Block is true for fenced code blocks, false for inline code.
+	Block bool
+}
+
+var tokenRegex = regexp.MustCompile(
+	"(?P<block>```(?s:.*?)```)" +
+		"|(?P<code>`[^`\n]+`)" +
+		`|(?P<mention>(?i:nostr:)(?:npub|nprofile|note|nevent|naddr)1[02-9ac-hj-np-z]+)` +
+		`|(?P<legacy>#\[\d+\])` +
+		`|(?P<url>(?i:https?://)[^\s<>"` + "`" + `]+)` +
+		`|(?P<lightning>\b(?i:(?:lightning:)?(?:ln(?:bc|tb|tbs|bcrt)[0-9a-z]*1[02-9ac-hj-np-z]+|lnurl1[02-9ac-hj-np-z]+)))` +
+		`|(?P<cashu>\bcashu[AB][A-Za-z0-9_\-+/=]+)` +
+		`|(?P<hashtag>#[\p{L}\p{N}_]+)` +
+		`|(?P<emoji>:[a-zA-Z0-9_]+:)`,
+)
+
+var (
+	groupBlock     = tokenRegex.SubexpIndex("block")
+	groupCode      = tokenRegex.SubexpIndex("code")
+	groupMention   = tokenRegex.SubexpIndex("mention")
+	groupLegacy    = tokenRegex.SubexpIndex("legacy")
+	groupURL       = tokenRegex.SubexpIndex("url")
+	groupLightning = tokenRegex.SubexpIndex("lightning")
+	groupCashu     = tokenRegex.SubexpIndex("cashu")
+	groupHashtag   = tokenRegex.SubexpIndex("hashtag")
+	groupEmoji     = tokenRegex.SubexpIndex("emoji")
+)
+
+// Tokenize splits the content of the event into ordered segments that cover all of it. Tags are used to
+// resolve legacy "#[n]" mentions, custom emojis (which are left as text when there is no "emoji" tag for
+// them) and to tell which hashtags are tagged. Nothing is recognized inside code.
+func Tokenize(evt *nostr.Event) []Segment {
+	content := evt.Content
+	segments := make([]Segment, 0, 4)
+
+	textStart := 0
+	pos := 0
+	for pos < len(content) {
+		match := tokenRegex.FindStringSubmatchIndex(content[pos:])
+		if match == nil {
+			break
+		}
+		for i := range match {
+			if match[i] != -1 {
+				match[i] += pos
+			}
+		}
+
+		seg, ok := parseToken(evt, content, match)
+		if !ok {
+			// not really a token, try again right after where this one started
+			_, size := utf8.DecodeRuneInString(content[match[0]:])
+			pos = match[0] + size
+			continue
+		}
+
+		if seg.Start > textStart {
+			segments = append(segments, textSegment(content, textStart, seg.Start))
+		}
+		segments = append(segments, seg)
+		textStart = seg.End
+		pos = seg.End
+	}
+
+	if textStart < len(content) {
+		segments = append(segments, textSegment(content, textStart, len(content)))
+	}
+
+	return segments
+}
+
+func textSegment(content string, start, end int) Segment {
+	return Segment{Type: Text, Start: start, End: end, Text: content[start:end], Value: content[start:end]}
+}
+
+func parseToken(evt *nostr.Event, content string, match []int) (Segment, bool) {
+	start, end := match[0], match[1]
+	seg := Segment{Start: start, End: end, Text: content[start:end]}
+
+	switch {
+	case match[2*groupBlock] != -1:
+		seg.Type = Code
+		seg.Block = true
+		seg.Value = strings.TrimSuffix(strings.TrimPrefix(seg.Text, "```"), "```")
+
+	case match[2*groupCode] != -1:
+		seg.Type = Code
+		seg.Value = seg.Text[1 : len(seg.Text)-1]
+
+	case match[2*groupMention] != -1:
+		pointer, err := nip21.Parse(seg.Text)
+		if err != nil {
+			return seg, false
+		}
+		seg.Type = Mention
+		seg.Pointer = pointer
+
+	case match[2*groupLegacy] != -1:
+		idx, err := strconv.Atoi(seg.Text[2 : len(seg.Text)-1])
+		if err != nil || idx >= len(evt.Tags) {
+			return seg, false
+		}
+		pointer := pointerFromTag(evt.Tags[idx])
+		if pointer == nil {
+			return seg, false
+		}
+		seg.Type = Mention
+		seg.Pointer = pointer
+
+	case match[2*groupURL] != -1:
+		seg.End = start + trimURL(seg.Text)
+		seg.Text = content[start:seg.End]
+		u, err := url.Parse(seg.Text)
+		if err != nil || u.Host == "" {
+			return seg, false
+		}
+		seg.Type = URL
+		seg.Value = seg.Text
+		seg.MediaType = guessMediaType(u.Path)
+
+	case match[2*groupLightning] != -1:
+		seg.Type = Lightning
+		seg.Value = seg.Text
+		if len(seg.Value) > 10 && strings.EqualFold(seg.Value[0:10], "lightning:") {
+			seg.Value = seg.Value[10:]
+		}
+
+	case match[2*groupCashu] != -1:
+		seg.Type = Cashu
+		seg.Value = seg.Text
+
+	case match[2*groupHashtag] != -1:
+		if start > 0 {
+			// hashtags must not be glued to a previous word
+			if r, _ := utf8.DecodeLastRuneInString(content[0:start]); r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) {
+				return seg, false
+			}
+		}
+		name := seg.Text[1:]
+		if strings.IndexFunc(name, func(r rune) bool { return !unicode.IsNumber(r) }) == -1 {
+			return seg, false
+		}
+		seg.Type = Hashtag
+		seg.Value = name
+		seg.Tagged = evt.Tags.ContainsAny("t", []string{name, strings.ToLower(name)})
+
+	case match[2*groupEmoji] != -1:
+		shortcode := seg.Text[1 : len(seg.Text)-1]
+		tag := evt.Tags.GetFirst([]string{"emoji", shortcode, ""})
+		if tag == nil {
+			return seg, false
+		}
+		seg.Type = Emoji
+		seg.Value = shortcode
+		seg.EmojiURL = (*tag)[2]
+
+	default:
+		return seg, false
+	}
+
+	return seg, true
+}
+
+func pointerFromTag(tag nostr.Tag) any {
+	if len(tag) < 2 {
+		return nil
+	}
+
+	var relays []string
+	if len(tag) > 2 && tag[2] != "" {
+		relays = []string{tag[2]}
+	}
+
+	switch tag[0] {
+	case "p":
+		if nostr.IsValid32ByteHex(tag[1]) {
+			return nostr.ProfilePointer{PublicKey: tag[1], Relays: relays}
+		}
+	case "e", "q":
+		if nostr.IsValid32ByteHex(tag[1]) {
+			return nostr.EventPointer{ID: tag[1], Relays: relays}
+		}
+	case "a":
+		if addr, err := nostr.ParseAddress(tag[1]); err == nil {
+			return addr.Pointer(relays...)
+		}
+	}
+	return nil
+}
+
+// trimURL returns the length of the URL without trailing punctuation that most likely belongs
+// to the surrounding text, like in "(see https://example.com/a_(b))."
+func trimURL(u string) int {
+	for len(u) > 0 {
+		last := u[len(u)-1]
+		switch last {
+		case '.', ',', ';', ':', '!', '?', '\'', '*':
+		case ')':
+			if strings.Count(u, "(") >= strings.Count(u, ")") {
+				return len(u)
+			}
+		default:
+			return len(u)
+		}
+		u = u[:len(u)-1]
+	}
+	return 0
+}
+
+var mediaTypes = map[string]string{
+	".jpg":  "image",
+	".jpeg": "image",
+	".png":  "image",
+	".gif":  "image",
+	".webp": "image",
+	".avif": "image",
+	".svg":  "image",
+	".bmp":  "image",
+	".mp4":  "video",
+	".webm": "video",
+	".mov":  "video",
+	".m4v":  "video",
+	".mkv":  "video",
+	".ogv":  "video",
+	".mp3":  "audio",
+	".ogg":  "audio",
+	".oga":  "audio",
+	".wav":  "audio",
+	".flac": "audio",
+	".m4a":  "audio",
+	".aac":  "audio",
+	".opus": "audio",
+}
+
+func guessMediaType(urlPath string) string {
+	return mediaTypes[strings.ToLower(path.Ext(urlPath))]
+}
@@ -0,0 +1,121 @@
+package nip27
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip21"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	pk = "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	id = "45326f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751ac194"
+)
+
+func types(segments []Segment) []SegmentType {
+	res := make([]SegmentType, len(segments))
+	for i, seg := range segments {
+		res[i] = seg.Type
+	}
+	return res
+}
+
+func TestTokenize(t *testing.T) {
+	evt := &nostr.Event{
+		Tags: nostr.Tags{
+			{"p", pk, "wss://relay.com"},
+			{"t", "nostr"},
+			{"emoji", "soapbox", "https://example.com/soapbox.png"},
+		},
+		Content: "hi #[0]! look (https://example.com/cat.JPG?x=1) #Nostr #go, 10:30:00 :soapbox: :unknown:\n" +
+			"nostr:npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6 pay lightning:lnbc10u1pexample " +
+			"or LNURL1DP68GURN8GHJ7UM9WFMXJCM99E3K7MF0V9CXJ0M385EKVCENXC6R2C35XVUKXEFCV5MKVV34X5EKZD3EV56NYD3HXQURZEPEXEJXXEPNXSCRVWFNV9NXZCN9XQ6XYEFHVGCXXCMYXYMNSERXFQ5FNS " +
+			"cashuAeyJ0b2tlbiI6W119 `#not :soapbox:` x#no #123\n```\nfunc main() {}\n```",
+	}
+
+	segments := Tokenize(evt)
+	require.Equal(t, []SegmentType{
+		Text, Mention, Text, URL, Text, Hashtag, Text, Hashtag, Text, Emoji, Text,
+		Mention, Text, Lightning, Text, Lightning, Text, Cashu, Text, Code, Text, Code,
+	}, types(segments))
+
+	// segments cover the whole content
+	end := 0
+	for _, seg := range segments {
+		require.Equal(t, end, seg.Start)
+		require.Equal(t, evt.Content[seg.Start:seg.End], seg.Text)
+		end = seg.End
+	}
+	require.Equal(t, len(evt.Content), end)
+
+	require.Equal(t, nostr.ProfilePointer{PublicKey: pk, Relays: []string{"wss://relay.com"}}, segments[1].Pointer)
+	require.Equal(t, "https://example.com/cat.JPG?x=1", segments[3].Value)
+	require.Equal(t, "image", segments[3].MediaType)
+	require.Equal(t, "Nostr", segments[5].Value)
+	require.True(t, segments[5].Tagged)
+	require.False(t, segments[7].Tagged)
+	require.Equal(t, "https://example.com/soapbox.png", segments[9].EmojiURL)
+	require.Equal(t, nostr.ProfilePointer{PublicKey: pk}, segments[11].Pointer)
+	require.Equal(t, "lnbc10u1pexample", segments[13].Value)
+	require.Equal(t, "#not :soapbox:", segments[19].Value)
+	require.False(t, segments[19].Block)
+	require.Equal(t, "\nfunc main() {}\n", segments[21].Value)
+	require.True(t, segments[21].Block)
+
+	// tokenizing and composing gives back the same content, but legacy mentions are upgraded
+	content, _, err := Compose(segments)
+	require.NoError(t, err)
+	uri, _ := nip21.Format(segments[1].Pointer)
+	require.Equal(t, "hi "+uri+evt.Content[7:], content)
+}
+
+func TestCompose(t *testing.T) {
+	content, tags, err := Compose([]Segment{
+		{Type: Text, Value: "gm "},
+		{Type: Mention, Pointer: nostr.ProfilePointer{PublicKey: pk}},
+		{Type: Text, Value: ", see "},
+		{Type: Mention, Pointer: nostr.EventPointer{ID: id, Author: pk}},
+		{Type: Text, Value: " and "},
+		{Type: Mention, Pointer: nostr.EntityPointer{PublicKey: pk, Kind: 30023, Identifier: "x", Relays: []string{"wss://r.com"}}},
+		{Type: Text, Value: " "},
+		{Type: Hashtag, Value: "GM"},
+		{Type: Text, Value: " "},
+		{Type: Emoji, Value: "sun", EmojiURL: "https://example.com/sun.png"},
+		{Type: Code, Value: "x := 1"},
+	})
+	require.NoError(t, err)
+
+	segments := Tokenize(&nostr.Event{Content: content, Tags: tags})
+	require.Equal(t, []SegmentType{Text, Mention, Text, Mention, Text, Mention, Text, Hashtag, Text, Emoji, Code}, types(segments))
+	require.True(t, segments[7].Tagged)
+
+	require.Equal(t, nostr.Tags{
+		{"p", pk},
+		{"q", id, "", pk},
+		{"q", "30023:" + pk + ":x", "wss://r.com", pk},
+		{"t", "gm"},
+		{"emoji", "sun", "https://example.com/sun.png"},
+	}, tags)
+}
+
+func FuzzTokenize(f *testing.F) {
+	f.Add("hi #[0] https://x.com/a_(b)). #tag :e: `code` ```block``` lnbc1x cashuAx")
+	f.Add("#été nostr:npub1 (http://a.b/c.mp4)")
+	f.Fuzz(func(t *testing.T, content string) {
+		evt := &nostr.Event{
+			Content: content,
+			Tags:    nostr.Tags{{"p", pk}, {"emoji", "e", "https://example.com/e.png"}, {"t", "tag"}},
+		}
+		segments := Tokenize(evt)
+
+		end := 0
+		for _, seg := range segments {
+			require.Equal(t, end, seg.Start)
+			require.Less(t, seg.Start, seg.End)
+			require.Equal(t, content[seg.Start:seg.End], seg.Text)
+			end = seg.End
+		}
+		require.Equal(t, len(content), end)
+	})
+}
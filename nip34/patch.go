@@ -21,25 +21,11 @@ func ParsePatch(event nostr.Event) Patch {
 		Event: event,
 	}
 
-	for _, tag := range event.Tags {
-		if len(tag) < 2 {
-			continue
-		}
-		switch tag[0] {
-		case "a":
-			spl := strings.Split(tag[1], ":")
-			if len(spl) != 3 {
-				continue
-			}
-			if !nostr.IsValid32ByteHex(spl[1]) {
-				continue
-			}
-			patch.Repository.Kind = nostr.KindRepositoryAnnouncement
-			patch.Repository.PublicKey = spl[1]
-			patch.Repository.Identifier = spl[2]
-			if len(tag) >= 3 {
-				patch.Repository.Relays = []string{tag[2]}
-			}
+	for addr, relay := range event.Tags.Addresses() {
+		addr.Kind = nostr.KindRepositoryAnnouncement
+		patch.Repository = addr.Pointer()
+		if relay != "" {
+			patch.Repository.Relays = []string{relay}
 		}
 	}
 
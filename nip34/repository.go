@@ -2,7 +2,6 @@ package nip34
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/nbd-wtf/go-nostr"
 )
@@ -115,7 +114,11 @@ func (repo Repository) FetchState(ctx context.Context, s nostr.RelayStore) *Repo
 func (repo Repository) GetPatchesSync(ctx context.Context, s nostr.RelayStore) []Patch {
 	res, _ := s.QuerySync(ctx, nostr.Filter{
 		Kinds: []int{nostr.KindPatch},
-		Tags:  nostr.TagMap{}.SetLiterals("a", fmt.Sprintf("%d:%s:%s", nostr.KindRepositoryAnnouncement, repo.Event.PubKey, repo.ID)),
+		Tags: nostr.TagMap{}.SetLiterals("a", nostr.Address{
+			Kind:       nostr.KindRepositoryAnnouncement,
+			PubKey:     repo.Event.PubKey,
+			Identifier: repo.ID,
+		}.String()),
 	})
 	patches := make([]Patch, len(res))
 	for i, evt := range res {
@@ -0,0 +1,211 @@
+package nip40
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fiatjaf/eventstore"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Sweeper wraps an eventstore.Store and deletes events from it once their NIP-40 expiration passes.
+// Events saved through it are tracked automatically, events that were already in the store must be
+// found with Scan. Expired events that weren't deleted yet are never returned by QueryEvents.
+//
+// Run must be running for events to be deleted as they expire, otherwise Sweep can be called from time
+// to time.
+type Sweeper struct {
+	eventstore.Store
+
+	// RetryInterval is how long Run waits before trying again when deleting fails, 30 seconds by default.
+	RetryInterval time.Duration
+
+	mu      sync.Mutex
+	queue   expirationQueue
+	tracked map[string]struct{}
+	wake    chan struct{}
+}
+
+var _ eventstore.Store = (*Sweeper)(nil)
+
+func NewSweeper(store eventstore.Store) *Sweeper {
+	return &Sweeper{
+		Store:         store,
+		RetryInterval: 30 * time.Second,
+		tracked:       make(map[string]struct{}),
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+type expiring struct {
+	evt *nostr.Event
+	exp nostr.Timestamp
+}
+
+type expirationQueue []expiring
+
+func (q expirationQueue) Len() int           { return len(q) }
+func (q expirationQueue) Less(i, j int) bool { return q[i].exp < q[j].exp }
+func (q expirationQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *expirationQueue) Push(x any)        { *q = append(*q, x.(expiring)) }
+func (q *expirationQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = expiring{}
+	*q = old[:len(old)-1]
+	return item
+}
+
+// Track schedules the deletion of an event that is in the store, does nothing if it doesn't expire or
+// is already tracked.
+func (s *Sweeper) Track(evt *nostr.Event) {
+	exp, ok := evt.Expiration()
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	if _, ok := s.tracked[evt.ID]; ok {
+		s.mu.Unlock()
+		return
+	}
+	s.tracked[evt.ID] = struct{}{}
+	heap.Push(&s.queue, expiring{evt, exp})
+	first := s.queue[0].evt == evt
+	s.mu.Unlock()
+
+	if first {
+		// Run may have to wake up earlier now
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Scan tracks all the expiring events in the store that match the filter.
+func (s *Sweeper) Scan(ctx context.Context, filter nostr.Filter) error {
+	ch, err := s.Store.QueryEvents(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("failed to query events: %w", err)
+	}
+	for evt := range ch {
+		s.Track(evt)
+	}
+	return nil
+}
+
+// Sweep deletes all tracked events that are expired and returns how many were deleted. Events that fail
+// to be deleted are kept to be tried again on the next call and their errors are returned together.
+func (s *Sweeper) Sweep(ctx context.Context) (int, error) {
+	now := nostr.Now()
+	deleted := 0
+	var failed []expiring
+	var errs []error
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 || s.queue[0].exp > now {
+			for _, item := range failed {
+				heap.Push(&s.queue, item)
+			}
+			s.mu.Unlock()
+			return deleted, errors.Join(errs...)
+		}
+		item := heap.Pop(&s.queue).(expiring)
+		s.mu.Unlock()
+
+		if err := s.Store.DeleteEvent(ctx, item.evt); err != nil {
+			failed = append(failed, item)
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", item.evt.ID, err))
+			continue
+		}
+
+		s.mu.Lock()
+		delete(s.tracked, item.evt.ID)
+		s.mu.Unlock()
+		deleted++
+	}
+}
+
+// Run sweeps every time a tracked event expires, until ctx is canceled. When deleting fails the error is
+// logged and it is tried again after RetryInterval.
+func (s *Sweeper) Run(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var retryAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.wake:
+		case <-timer.C:
+			if _, err := s.Sweep(ctx); err != nil {
+				nostr.InfoLogger.Printf("[nip40] %s\n", err)
+				retryAt = time.Now().Add(s.RetryInterval)
+			} else {
+				retryAt = time.Time{}
+			}
+		}
+
+		s.mu.Lock()
+		next := time.Hour
+		if len(s.queue) > 0 {
+			next = time.Until(s.queue[0].exp.Time())
+		}
+		s.mu.Unlock()
+
+		// don't insist on events that just failed to be deleted
+		next = max(next, time.Until(retryAt), 0)
+		timer.Reset(next)
+	}
+}
+
+func (s *Sweeper) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	if evt.IsExpired() {
+		return nil
+	}
+	if err := s.Store.SaveEvent(ctx, evt); err != nil {
+		return err
+	}
+	s.Track(evt)
+	return nil
+}
+
+func (s *Sweeper) ReplaceEvent(ctx context.Context, evt *nostr.Event) error {
+	if evt.IsExpired() {
+		return nil
+	}
+	if err := s.Store.ReplaceEvent(ctx, evt); err != nil {
+		return err
+	}
+	s.Track(evt)
+	return nil
+}
+
+func (s *Sweeper) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
+	ch, err := s.Store.QueryEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make(chan *nostr.Event)
+	go func() {
+		defer close(filtered)
+		for evt := range ch {
+			if evt.IsExpired() {
+				continue
+			}
+			select {
+			case filtered <- evt:
+			case <-ctx.Done():
+				// keep draining so the underlying store isn't blocked
+			}
+		}
+	}()
+	return filtered, nil
+}
@@ -0,0 +1,130 @@
+package nip40
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/test_common"
+	"github.com/stretchr/testify/require"
+)
+
+func makeEvent(t *testing.T, exp nostr.Timestamp) *nostr.Event {
+	evt := &nostr.Event{Kind: nostr.KindTextNote, CreatedAt: nostr.Now(), Content: "hello"}
+	if exp != 0 {
+		evt.SetExpiration(exp)
+	}
+	require.NoError(t, evt.Sign(nostr.GeneratePrivateKey()))
+	return evt
+}
+
+func TestSweeper(t *testing.T) {
+	ctx := context.Background()
+	store := &test_common.MemoryStore{}
+	require.NoError(t, store.Init())
+
+	// already in the store before the sweeper
+	old := makeEvent(t, nostr.Now()-10)
+	require.NoError(t, store.SaveEvent(ctx, old))
+
+	sweeper := NewSweeper(store)
+	permanent := makeEvent(t, 0)
+	later := makeEvent(t, nostr.Now()+3600)
+	expired := makeEvent(t, nostr.Now()-1)
+	for _, evt := range []*nostr.Event{permanent, later, expired} {
+		require.NoError(t, sweeper.SaveEvent(ctx, evt))
+	}
+	require.False(t, store.Has(expired.ID), "expired events shouldn't be saved")
+
+	// not returned even before being deleted
+	ch, err := sweeper.QueryEvents(ctx, nostr.Filter{})
+	require.NoError(t, err)
+	var ids []string
+	for evt := range ch {
+		ids = append(ids, evt.ID)
+	}
+	require.ElementsMatch(t, []string{permanent.ID, later.ID}, ids)
+
+	n, err := sweeper.Sweep(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.True(t, store.Has(old.ID))
+
+	// scanning many times doesn't track the same events again
+	require.NoError(t, sweeper.Scan(ctx, nostr.Filter{}))
+	require.NoError(t, sweeper.Scan(ctx, nostr.Filter{}))
+	require.Len(t, sweeper.queue, 2)
+	n, err = sweeper.Sweep(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.False(t, store.Has(old.ID))
+	require.True(t, store.Has(permanent.ID))
+	require.True(t, store.Has(later.ID))
+}
+
+func TestSweeperRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store := &test_common.MemoryStore{}
+	require.NoError(t, store.Init())
+	sweeper := NewSweeper(store)
+
+	done := make(chan error)
+	go func() { done <- sweeper.Run(ctx) }()
+
+	evt := makeEvent(t, nostr.Now()+1)
+	require.NoError(t, sweeper.SaveEvent(ctx, evt))
+	require.True(t, store.Has(evt.ID))
+
+	require.Eventually(t, func() bool { return !store.Has(evt.ID) }, 4*time.Second, 50*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestSweeperFailures(t *testing.T) {
+	ctx := context.Background()
+	store := &test_common.MemoryStore{}
+	require.NoError(t, store.Init())
+	sweeper := NewSweeper(store)
+
+	evt := makeEvent(t, nostr.Now()-1)
+	require.NoError(t, store.SaveEvent(ctx, evt))
+	require.NoError(t, sweeper.Scan(ctx, nostr.Filter{}))
+
+	// the event is kept to be tried again
+	store.FailDeletes = 1
+	n, err := sweeper.Sweep(ctx)
+	require.Error(t, err)
+	require.Zero(t, n)
+	require.True(t, store.Has(evt.ID))
+
+	n, err = sweeper.Sweep(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.False(t, store.Has(evt.ID))
+}
+
+func TestSweeperRunRetries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store := &test_common.MemoryStore{FailDeletes: 2}
+	require.NoError(t, store.Init())
+	sweeper := NewSweeper(store)
+	sweeper.RetryInterval = 50 * time.Millisecond
+
+	evt := makeEvent(t, nostr.Now()-1)
+	require.NoError(t, store.SaveEvent(ctx, evt))
+	require.NoError(t, sweeper.Scan(ctx, nostr.Filter{}))
+
+	done := make(chan error)
+	go func() { done <- sweeper.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return !store.Has(evt.ID) }, 4*time.Second, 20*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,189 @@
+package nip50
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// KnownExtensions are the keys that will be parsed as extensions when they appear as "key:value" in a
+// search string. Other "something:else" tokens (like URLs) are treated as normal terms.
+// Custom extensions supported by specific relays can be appended here.
+var KnownExtensions = []string{"include", "domain", "language", "sentiment", "nsfw"}
+
+// Extension is a "key:value" token in a NIP-50 search string.
+type Extension struct {
+	Key   string
+	Value string
+}
+
+// Query is a parsed NIP-50 search string.
+type Query struct {
+	// Terms are the words or "quoted phrases" to search for.
+	Terms      []string
+	Extensions []Extension
+}
+
+// Parse splits a search string into terms and extensions. Phrases can be grouped with double quotes, inside
+// which \" and \\ stand for a literal quote and backslash.
+func Parse(search string) Query {
+	q := Query{}
+
+	for _, token := range tokenize(search) {
+		if !token.quoted {
+			if key, value, ok := strings.Cut(token.text, ":"); ok && value != "" && slices.Contains(KnownExtensions, key) {
+				q.Extensions = append(q.Extensions, Extension{key, value})
+				continue
+			}
+		}
+		q.Terms = append(q.Terms, token.text)
+	}
+
+	return q
+}
+
+// String builds the search string back, it can be used as the "search" field of a filter. Parsing it
+// gives the same query back.
+func (q Query) String() string {
+	parts := make([]string, 0, len(q.Terms)+len(q.Extensions))
+	for _, term := range q.Terms {
+		if strings.ContainsAny(term, " \t\n\"") || (strings.Contains(term, ":") && slices.Contains(KnownExtensions, strings.SplitN(term, ":", 2)[0])) {
+			term = `"` + quoteEscaper.Replace(term) + `"`
+		}
+		parts = append(parts, term)
+	}
+	for _, ext := range q.Extensions {
+		parts = append(parts, ext.Key+":"+ext.Value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Get returns the value of the first extension with the given key.
+func (q Query) Get(key string) (string, bool) {
+	for _, ext := range q.Extensions {
+		if ext.Key == key {
+			return ext.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set replaces all extensions with the given key by a single one with the given value.
+func (q *Query) Set(key string, value string) {
+	q.Extensions = slices.DeleteFunc(q.Extensions, func(ext Extension) bool { return ext.Key == key })
+	q.Extensions = append(q.Extensions, Extension{key, value})
+}
+
+// IncludeSpam tells if "include:spam" was given, asking relays not to filter out spam.
+func (q Query) IncludeSpam() bool {
+	for _, ext := range q.Extensions {
+		if ext.Key == "include" && ext.Value == "spam" {
+			return true
+		}
+	}
+	return false
+}
+
+// Domain returns the "domain:" extension, used to search for users with NIP-05 addresses in that domain.
+func (q Query) Domain() string {
+	v, _ := q.Get("domain")
+	return v
+}
+
+// Language returns the "language:" extension (a two-letter ISO 639-1 code).
+func (q Query) Language() string {
+	v, _ := q.Get("language")
+	return v
+}
+
+// Sentiment returns the "sentiment:" extension ("negative", "neutral" or "positive").
+func (q Query) Sentiment() string {
+	v, _ := q.Get("sentiment")
+	return v
+}
+
+// NSFW returns the value of the "nsfw:" extension and whether it was given at all.
+func (q Query) NSFW() (nsfw bool, ok bool) {
+	v, ok := q.Get("nsfw")
+	return v == "true", ok
+}
+
+// Matches does a basic local full-text evaluation of the query: all terms must be present in the content
+// (case-insensitively) and, if a "domain:" extension is given, the event must be a profile with a NIP-05
+// address in that domain. Other extensions can't be evaluated locally and are ignored.
+func (q Query) Matches(evt *nostr.Event) bool {
+	content := strings.ToLower(evt.Content)
+	for _, term := range q.Terms {
+		if !strings.Contains(content, strings.ToLower(term)) {
+			return false
+		}
+	}
+
+	if domain := q.Domain(); domain != "" {
+		if evt.Kind != nostr.KindProfileMetadata {
+			return false
+		}
+		var metadata struct {
+			NIP05 string `json:"nip05"`
+		}
+		if err := json.Unmarshal([]byte(evt.Content), &metadata); err != nil {
+			return false
+		}
+		if !strings.HasSuffix(strings.ToLower(metadata.NIP05), "@"+strings.ToLower(domain)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(search string) []token {
+	var tokens []token
+	var current strings.Builder
+	quoted := false
+	wasQuoted := false
+	escaped := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, token{current.String(), wasQuoted})
+		}
+		current.Reset()
+		wasQuoted = false
+	}
+
+	for _, r := range search {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			if quoted {
+				quoted = false
+				flush()
+			} else {
+				flush()
+				quoted = true
+				wasQuoted = true
+			}
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
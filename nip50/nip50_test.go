@@ -0,0 +1,53 @@
+package nip50
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	q := Parse(`"exact phrase"  bitcoin include:spam domain:example.com https://x.com "nsfw:true"`)
+
+	require.Equal(t, []string{"exact phrase", "bitcoin", "https://x.com", "nsfw:true"}, q.Terms)
+	require.Equal(t, []Extension{{"include", "spam"}, {"domain", "example.com"}}, q.Extensions)
+	require.True(t, q.IncludeSpam())
+	require.Equal(t, "example.com", q.Domain())
+	require.Equal(t, "", q.Language())
+	_, ok := q.NSFW()
+	require.False(t, ok)
+
+	require.Equal(t, `"exact phrase" bitcoin https://x.com "nsfw:true" include:spam domain:example.com`, q.String())
+	require.Equal(t, q, Parse(q.String()))
+
+	// quotes and backslashes survive the round trip
+	q = Query{Terms: []string{`a"b`, `say "hi"`, `back\slash`, `"`, `in \"both\"`}}
+	require.Equal(t, `"a\"b" "say \"hi\"" back\slash "\"" "in \\\"both\\\""`, q.String())
+	require.Equal(t, q, Parse(q.String()))
+	require.Equal(t, []string{`a"b`}, Parse(`"a\"b"`).Terms)
+}
+
+func TestBuild(t *testing.T) {
+	q := Query{Terms: []string{"nostr"}}
+	q.Set("language", "en")
+	q.Set("nsfw", "true")
+	q.Set("language", "pt")
+
+	require.Equal(t, "nostr nsfw:true language:pt", q.String())
+	require.Equal(t, "pt", q.Language())
+	nsfw, ok := q.NSFW()
+	require.True(t, ok)
+	require.True(t, nsfw)
+}
+
+func TestMatches(t *testing.T) {
+	note := &nostr.Event{Kind: 1, Content: "I like Exact Phrases about Bitcoin"}
+	require.True(t, Parse(`"exact phrase" bitcoin language:en`).Matches(note))
+	require.False(t, Parse(`"exact bitcoin"`).Matches(note))
+	require.False(t, Parse("domain:example.com").Matches(note))
+
+	profile := &nostr.Event{Kind: 0, Content: `{"name":"bob","nip05":"bob@Example.com"}`}
+	require.True(t, Parse("bob domain:example.com").Matches(profile))
+	require.False(t, Parse("domain:other.com").Matches(profile))
+}
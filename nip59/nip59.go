@@ -11,6 +11,8 @@ import (
 
 // GiftWrap takes a 'rumor', encrypts it with our own key, making a 'seal', then encrypts that with a nonce key and
 // signs that (after potentially applying a modify function, which can be nil otherwise), yielding a 'gift-wrap'.
+//
+// If the rumor has a NIP-40 expiration the gift-wrap gets the same, so relays can delete it when it expires.
 func GiftWrap(
 	rumor nostr.Event,
 	recipient string,
@@ -54,6 +56,9 @@ func GiftWrap(
 			nostr.Tag{"p", recipient},
 		},
 	}
+	if exp, ok := rumor.Expiration(); ok {
+		gw.SetExpiration(exp)
+	}
 	if modify != nil {
 		modify(&gw)
 	}
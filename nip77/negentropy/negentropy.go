@@ -158,18 +158,25 @@ func (n *Negentropy) reconcileAux(reader *StringHexReader) (string, error) {
 			}
 
 			// what they have
-			theirItems := make(map[string]struct{}, numIds)
+			theirItems := make(map[nostr.ID]struct{}, numIds)
 			for i := 0; i < numIds; i++ {
-				if id, err := reader.ReadString(64); err != nil {
+				hexID, err := reader.ReadString(64)
+				if err != nil {
 					return "", fmt.Errorf("failed to read id (#%d/%d) in list: %w", i, numIds, err)
-				} else {
-					theirItems[id] = struct{}{}
 				}
+				id, err := nostr.ParseID(hexID)
+				if err != nil {
+					return "", fmt.Errorf("invalid id (#%d/%d) in list: %w", i, numIds, err)
+				}
+				theirItems[id] = struct{}{}
 			}
 
 			// what we have
 			for _, item := range n.storage.Range(lower, upper) {
-				id := item.ID
+				id, err := nostr.ParseID(item.ID)
+				if err != nil {
+					return "", fmt.Errorf("invalid id %q in storage: %w", item.ID, err)
+				}
 
 				if _, theyHave := theirItems[id]; theyHave {
 					// if we have and they have, ignore
@@ -177,7 +184,7 @@ func (n *Negentropy) reconcileAux(reader *StringHexReader) (string, error) {
 				} else {
 					// if we have and they don't, notify client
 					if n.isClient {
-						n.Haves <- id
+						n.Haves <- item.ID
 					}
 				}
 			}
@@ -185,8 +192,7 @@ func (n *Negentropy) reconcileAux(reader *StringHexReader) (string, error) {
 			if n.isClient {
 				// notify client of what they have and we don't
 				for id := range theirItems {
-					// skip empty strings here because those were marked to be excluded as such in the previous step
-					n.HaveNots <- id
+					n.HaveNots <- id.String()
 				}
 
 				// client got list of ids, it's done, skip
@@ -1,7 +1,6 @@
 package vector
 
 import (
-	"encoding/hex"
 	"fmt"
 	"iter"
 	"slices"
@@ -12,6 +11,7 @@ import (
 
 type Vector struct {
 	items  []negentropy.Item
+	ids    []nostr.ID // binary ids in the same order as items, filled lazily
 	sealed bool
 
 	acc Accumulator
@@ -23,10 +23,15 @@ func New() *Vector {
 	}
 }
 
+// Insert adds an item to the vector, it panics if id isn't 64 characters of hex so callers must
+// check ids that come from untrusted places first.
 func (v *Vector) Insert(createdAt nostr.Timestamp, id string) {
 	if len(id) != 64 {
 		panic(fmt.Errorf("bad id size for added item: expected %d bytes, got %d", 32, len(id)/2))
 	}
+	if _, err := nostr.ParseID(id); err != nil {
+		panic(fmt.Errorf("bad id for added item: %w", err))
+	}
 
 	item := negentropy.Item{Timestamp: createdAt, ID: id}
 	v.items = append(v.items, item)
@@ -40,6 +45,7 @@ func (v *Vector) Seal() {
 	}
 	v.sealed = true
 	slices.SortFunc(v.items, negentropy.ItemCompare)
+	v.ids = v.ids[:0]
 }
 
 func (v *Vector) GetBound(idx int) negentropy.Bound {
@@ -67,10 +73,16 @@ func (v *Vector) FindLowerBound(begin, end int, bound negentropy.Bound) int {
 func (v *Vector) Fingerprint(begin, end int) string {
 	v.acc.Reset()
 
-	tmp := make([]byte, 32)
-	for _, item := range v.Range(begin, end) {
-		hex.Decode(tmp, []byte(item.ID))
-		v.acc.AddBytes(tmp)
+	if len(v.ids) != len(v.items) {
+		v.ids = v.ids[:0]
+		for _, item := range v.items {
+			id, _ := nostr.ParseID(item.ID) // already checked on Insert
+			v.ids = append(v.ids, id)
+		}
+	}
+
+	for _, id := range v.ids[begin:end] {
+		v.acc.AddBytes(id[:])
 	}
 
 	return v.acc.GetFingerprint(end - begin)
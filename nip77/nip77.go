@@ -3,6 +3,7 @@ package nip77
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 
 	"github.com/nbd-wtf/go-nostr"
@@ -21,6 +22,9 @@ func NegentropySync(ctx context.Context, store nostr.RelayStore, url string, fil
 	vec := vector.New()
 	neg := negentropy.New(vec, 1024*1024)
 	for _, evt := range data {
+		if _, err := nostr.ParseID(evt.ID); err != nil {
+			return fmt.Errorf("invalid id %q in our local store: %w", evt.ID, err)
+		}
 		vec.Insert(evt.CreatedAt, evt.ID)
 	}
 	vec.Seal()
@@ -28,7 +32,7 @@ func NegentropySync(ctx context.Context, store nostr.RelayStore, url string, fil
 	result := make(chan error)
 
 	var r *nostr.Relay
-	r, err = nostr.RelayConnect(ctx, url, nostr.WithCustomHandler(func(data []byte) {
+	r, err = nostr.RelayConnect(ctx, url, nostr.WithSignatureChecker(skipSignatureCheck), nostr.WithCustomHandler(func(data []byte) {
 		envelope := ParseNegMessage(data)
 		if envelope == nil {
 			return
@@ -74,13 +78,14 @@ func NegentropySync(ctx context.Context, store nostr.RelayStore, url string, fil
 		items  chan string
 		source nostr.RelayStore
 		target nostr.RelayStore
+		verify bool
 	}
 
 	wg := sync.WaitGroup{}
 	pool := newidlistpool(50)
 	for _, dir := range []direction{
-		{"up", neg.Haves, store, r},
-		{"down", neg.HaveNots, r, store},
+		{"up", neg.Haves, store, r, false},
+		{"down", neg.HaveNots, r, store, true},
 	} {
 		wg.Add(1)
 		go func(dir direction) {
@@ -100,7 +105,21 @@ func NegentropySync(ctx context.Context, store nostr.RelayStore, url string, fil
 					result <- fmt.Errorf("error querying source on %s: %w", dir.label, err)
 					return
 				}
+				events := make([]*nostr.Event, 0, len(ids))
 				for evt := range evtch {
+					events = append(events, evt)
+				}
+
+				if dir.verify {
+					// signatures were not checked when the events arrived, we do them all together here
+					if ok, bad := nostr.VerifyBatch(events); !ok {
+						for i := len(bad) - 1; i >= 0; i-- {
+							events = slices.Delete(events, bad[i], bad[i]+1)
+						}
+					}
+				}
+
+				for _, evt := range events {
 					dir.target.Publish(ctx, *evt)
 				}
 			}
@@ -136,3 +155,6 @@ func NegentropySync(ctx context.Context, store nostr.RelayStore, url string, fil
 
 	return nil
 }
+
+// skipSignatureCheck is used on the relay connection because we verify downloaded events in batches.
+func skipSignatureCheck(*nostr.Event) bool { return true }
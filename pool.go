@@ -1,6 +1,7 @@
 package nostr
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log"
@@ -23,14 +24,23 @@ type SimplePool struct {
 	Context context.Context
 
 	authHandler func(context.Context, RelayEvent) error
+	relayAuth   *WithRelayAuth
+	authStates  *xsync.MapOf[string, *relayAuthState]
 	cancel      context.CancelFunc
 
-	eventMiddleware []func(RelayEvent)
+	eventMiddleware  []func(RelayEvent)
+	newDeduplicator  func() Deduplicator
+	duplicateHandler func(id string, relays []string)
+	store            RelayStore
+
+	eventPolicies        []EventPolicy
+	rejectedEventHandler func(RelayEvent, error)
 
 	// custom things not often used
-	penaltyBoxMu sync.Mutex
-	penaltyBox   map[string][2]float64
-	userAgent    string
+	penaltyBoxMu  sync.Mutex
+	penaltyBox    map[string][2]float64
+	userAgent     string
+	keepRawEvents bool
 }
 
 type DirectedFilters struct {
@@ -40,10 +50,15 @@ type DirectedFilters struct {
 
 type RelayEvent struct {
 	*Event
+
+	// Relay is nil for events that come from the pool's store (see WithRelayStore).
 	Relay *Relay
 }
 
 func (ie RelayEvent) String() string {
+	if ie.Relay == nil {
+		return fmt.Sprintf("[local] >> %s", ie.Event)
+	}
 	return fmt.Sprintf("[%s] >> %s", ie.Relay.URL, ie.Event)
 }
 
@@ -70,7 +85,8 @@ func NewSimplePool(ctx context.Context, opts ...PoolOption) *SimplePool {
 
 // WithAuthHandler must be a function that signs the auth event when called.
 // it will be called whenever any relay in the pool returns a `CLOSED` message
-// with the "auth-required:" prefix, only once for each relay
+// with the "auth-required:" prefix, only once for each relay.
+// see WithRelayAuth for more control.
 type WithAuthHandler func(ctx context.Context, authEvent RelayEvent) error
 
 func (h WithAuthHandler) ApplyPoolOption(pool *SimplePool) {
@@ -130,11 +146,46 @@ func (h withUserAgentOpt) ApplyPoolOption(pool *SimplePool) {
 	pool.userAgent = string(h)
 }
 
+// WithDeduplicator sets the strategy used by SubMany and SubManyEose to drop events that were already
+// received from another relay. The function is called once for each subscription.
+// When not given, SubMany remembers all events until the first EOSE and from then on forgets those that
+// haven't been seen for a minute, and SubManyEose remembers all.
+type WithDeduplicator func() Deduplicator
+
+func (h WithDeduplicator) ApplyPoolOption(pool *SimplePool) {
+	pool.newDeduplicator = h
+}
+
+// WithDuplicateHandler is a function that will be called whenever an event is dropped for being a
+// duplicate, with the list of relays it has come from so far. Useful for keeping track of relay hints.
+type WithDuplicateHandler func(id string, relays []string)
+
+func (h WithDuplicateHandler) ApplyPoolOption(pool *SimplePool) {
+	pool.duplicateHandler = h
+}
+
+// WithRelayStore attaches a local store to the pool. SubManyEose (and so QuerySingle) will first
+// answer from it and only ask relays for events newer than the newest stored one, and events received
+// from relays by any subscription will be saved to it, as they were received (before any EventPolicy
+// rewrites them) but only if no policy rejects them. Events that come from the store are emitted
+// with a nil Relay.
+func WithRelayStore(store RelayStore) withRelayStoreOpt { return withRelayStoreOpt{store} }
+
+type withRelayStoreOpt struct{ store RelayStore }
+
+func (h withRelayStoreOpt) ApplyPoolOption(pool *SimplePool) {
+	pool.store = h.store
+}
+
 var (
 	_ PoolOption = (WithAuthHandler)(nil)
 	_ PoolOption = (WithEventMiddleware)(nil)
+	_ PoolOption = (WithDeduplicator)(nil)
+	_ PoolOption = (WithDuplicateHandler)(nil)
 	_ PoolOption = WithPenaltyBox()
 	_ PoolOption = WithUserAgent("")
+	_ PoolOption = WithRelayStore(nil)
+	_ PoolOption = WithRawEvents()
 )
 
 func (pool *SimplePool) EnsureRelay(url string) (*Relay, error) {
@@ -161,8 +212,13 @@ func (pool *SimplePool) EnsureRelay(url string) (*Relay, error) {
 	ctx, cancel := context.WithTimeout(pool.Context, time.Second*15)
 	defer cancel()
 
-	relay = NewRelay(context.Background(), url)
+	var opts []RelayOption
+	if pool.keepRawEvents {
+		opts = append(opts, WithRawEvents())
+	}
+	relay = NewRelay(context.Background(), url, opts...)
 	relay.RequestHeader.Set("User-Agent", pool.userAgent)
+	pool.setPreemptiveAuth(relay)
 
 	if err := relay.Connect(ctx); err != nil {
 		if pool.penaltyBox != nil {
@@ -195,6 +251,12 @@ func (pool *SimplePool) PublishMany(ctx context.Context, urls []string, evt Even
 				ch <- PublishResult{err, url, nil}
 			} else {
 				err = relay.Publish(ctx, evt)
+				if err != nil && strings.Contains(err.Error(), "auth-required:") && pool.canAuth(relay) {
+					// relay is requesting auth. if we can we will perform auth and try again once
+					if authErr := pool.authenticate(ctx, relay); authErr == nil {
+						err = relay.Publish(ctx, evt)
+					}
+				}
 				ch <- PublishResult{err, url, relay}
 			}
 		}
@@ -236,10 +298,15 @@ func (pool *SimplePool) subMany(
 	ctx, cancel := context.WithCancel(ctx)
 	_ = cancel // do this so `go vet` will stop complaining
 	events := make(chan RelayEvent)
-	seenAlready := xsync.NewMapOf[string, Timestamp]()
-	ticker := time.NewTicker(seenAlreadyDropTick)
-
-	eose := false
+	var dedup Deduplicator
+	var defaultDedup *TimeWindowDeduplicator
+	if pool.newDeduplicator != nil {
+		dedup = pool.newDeduplicator()
+	} else {
+		// by default we remember everything until the stored events are in
+		defaultDedup = newEOSETimeWindowDeduplicator(seenAlreadyDropTick)
+		dedup = defaultDedup
+	}
 
 	pending := xsync.NewCounter()
 	pending.Add(int64(len(urls)))
@@ -283,11 +350,6 @@ func (pool *SimplePool) subMany(
 					goto reconnect
 				}
 
-				go func() {
-					<-sub.EndOfStoredEvents
-					eose = true
-				}()
-
 				// reset interval when we get a good subscription
 				interval = 3 * time.Second
 
@@ -305,39 +367,37 @@ func (pool *SimplePool) subMany(
 							goto reconnect
 						}
 
-						ie := RelayEvent{Event: evt, Relay: relay}
+						// the store gets the event as it was received and verified, policies may rewrite it
+						received := pool.copyForStore(evt)
+						ie, ok := pool.applyEventPolicies(RelayEvent{Event: evt, Relay: relay})
+						if !ok {
+							continue
+						}
+						evt = ie.Event
+
 						for _, mh := range pool.eventMiddleware {
 							mh(ie)
 						}
 
-						if unique {
-							if _, seen := seenAlready.LoadOrStore(evt.ID, evt.CreatedAt); seen {
-								continue
-							}
+						if unique && pool.isDuplicate(dedup, ie) {
+							continue
 						}
 
+						pool.saveToStore(ctx, received)
+
 						select {
 						case events <- ie:
 						case <-ctx.Done():
 							return
 						}
-					case <-ticker.C:
-						if eose {
-							old := Timestamp(time.Now().Add(-seenAlreadyDropTick).Unix())
-							seenAlready.Range(func(id string, value Timestamp) bool {
-								if value < old {
-									seenAlready.Delete(id)
-								}
-								return true
-							})
+					case <-sub.EndOfStoredEvents:
+						if defaultDedup != nil {
+							defaultDedup.gotEOSE()
 						}
 					case reason := <-sub.ClosedReason:
-						if strings.HasPrefix(reason, "auth-required:") && pool.authHandler != nil && !hasAuthed {
+						if strings.HasPrefix(reason, "auth-required:") && pool.canAuth(relay) && !hasAuthed {
 							// relay is requesting auth. if we can we will perform auth and try again
-							err := relay.Auth(ctx, func(event *Event) error {
-								return pool.authHandler(ctx, RelayEvent{Event: event, Relay: relay})
-							})
-							if err == nil {
+							if err := pool.authenticate(ctx, relay); err == nil {
 								hasAuthed = true // so we don't keep doing AUTH again and again
 								goto subscribe
 							}
@@ -392,8 +452,46 @@ func (pool *SimplePool) subManyEose(
 	ctx, cancel := context.WithCancel(ctx)
 
 	events := make(chan RelayEvent)
-	seenAlready := xsync.NewMapOf[string, bool]()
+	var dedup Deduplicator
+	if pool.newDeduplicator != nil {
+		dedup = pool.newDeduplicator()
+	} else {
+		dedup = newMapDeduplicator()
+	}
 	wg := sync.WaitGroup{}
+
+	var stored []*Event
+	if pool.store != nil {
+		stored, filters = pool.queryStore(ctx, filters)
+	}
+	storedIDs := make(map[string]struct{}, len(stored))
+	for _, evt := range stored {
+		storedIDs[evt.ID] = struct{}{}
+	}
+
+	if len(stored) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, evt := range stored {
+				// stored events come from no relay, but they must still pass the policies
+				ie, ok := pool.applyEventPolicies(RelayEvent{Event: evt})
+				if !ok {
+					continue
+				}
+				select {
+				case events <- ie:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	if len(filters) == 0 {
+		// everything was found in the store
+		urls = nil
+	}
 	wg.Add(len(urls))
 
 	go func() {
@@ -428,12 +526,9 @@ func (pool *SimplePool) subManyEose(
 				case <-sub.EndOfStoredEvents:
 					return
 				case reason := <-sub.ClosedReason:
-					if strings.HasPrefix(reason, "auth-required:") && pool.authHandler != nil && !hasAuthed {
+					if strings.HasPrefix(reason, "auth-required:") && pool.canAuth(relay) && !hasAuthed {
 						// relay is requesting auth. if we can we will perform auth and try again
-						err := relay.Auth(ctx, func(event *Event) error {
-							return pool.authHandler(ctx, RelayEvent{Event: event, Relay: relay})
-						})
-						if err == nil {
+						if err := pool.authenticate(ctx, relay); err == nil {
 							hasAuthed = true // so we don't keep doing AUTH again and again
 							goto subscribe
 						}
@@ -445,17 +540,28 @@ func (pool *SimplePool) subManyEose(
 						return
 					}
 
-					ie := RelayEvent{Event: evt, Relay: relay}
+					// the store gets the event as it was received and verified, policies may rewrite it
+					received := pool.copyForStore(evt)
+					ie, ok := pool.applyEventPolicies(RelayEvent{Event: evt, Relay: relay})
+					if !ok {
+						continue
+					}
+					evt = ie.Event
+
 					for _, mh := range pool.eventMiddleware {
 						mh(ie)
 					}
 
-					if unique {
-						if _, seen := seenAlready.LoadOrStore(evt.ID, true); seen {
-							continue
-						}
+					if _, ok := storedIDs[evt.ID]; ok {
+						continue
 					}
 
+					if unique && pool.isDuplicate(dedup, ie) {
+						continue
+					}
+
+					pool.saveToStore(ctx, received)
+
 					select {
 					case events <- ie:
 					case <-ctx.Done():
@@ -469,37 +575,190 @@ func (pool *SimplePool) subManyEose(
 	return events
 }
 
-// CountMany aggregates count results from multiple relays using HyperLogLog
+// queryStore fetches events matching filters from the local store and returns them along with
+// the filters narrowed down to what we still have to ask relays for.
+func (pool *SimplePool) queryStore(ctx context.Context, filters Filters) ([]*Event, Filters) {
+	var stored []*Event
+	narrowed := make(Filters, 0, len(filters))
+
+	for _, filter := range filters {
+		res, err := pool.store.QuerySync(ctx, filter)
+		if err != nil {
+			debugLogf("error querying local store with %v: %s", filter, err)
+			narrowed = append(narrowed, filter)
+			continue
+		}
+		stored = append(stored, res...)
+
+		if len(filter.IDs) > 0 {
+			// when asking for specific ids we only need the ones we don't have
+			missing := make([]string, 0, len(filter.IDs))
+			for _, id := range filter.IDs {
+				if !slices.ContainsFunc(res, func(evt *Event) bool { return evt.ID == id }) {
+					missing = append(missing, id)
+				}
+			}
+			if len(missing) == 0 {
+				continue
+			}
+			filter.IDs = missing
+		} else if len(res) > 0 {
+			// otherwise only ask for what is newer than what we have
+			newest := slices.MaxFunc(res, func(a, b *Event) int { return cmp.Compare(a.CreatedAt, b.CreatedAt) }).CreatedAt
+			if filter.Since == nil || *filter.Since < newest {
+				filter.Since = &newest
+			}
+		}
+		narrowed = append(narrowed, filter)
+	}
+
+	return stored, narrowed
+}
+
+// copyForStore returns a deep copy of an event received from a relay so it can be saved to the local store
+// even after policies rewrite it, or nil if it won't be saved.
+func (pool *SimplePool) copyForStore(evt *Event) *Event {
+	if pool.store == nil || IsEphemeralKind(evt.Kind) {
+		return nil
+	}
+	received := *evt
+	if evt.Tags != nil {
+		received.Tags = make(Tags, len(evt.Tags))
+		for i, tag := range evt.Tags {
+			received.Tags[i] = slices.Clone(tag)
+		}
+	}
+	return &received
+}
+
+// saveToStore saves an event returned by copyForStore to the local store.
+func (pool *SimplePool) saveToStore(ctx context.Context, evt *Event) {
+	if evt == nil {
+		return
+	}
+	if err := pool.store.Publish(ctx, *evt); err != nil {
+		debugLogf("error saving %s to local store: %s", evt.ID, err)
+	}
+}
+
+// isDuplicate checks the event against the subscription's deduplicator and calls the duplicate handler if needed.
+func (pool *SimplePool) isDuplicate(dedup Deduplicator, ie RelayEvent) bool {
+	if pool.duplicateHandler == nil {
+		return seenBefore(dedup, ie.ID, ie.Relay.URL)
+	}
+	seen, relays := dedup.Seen(ie.ID, ie.Relay.URL)
+	if seen && pool.duplicateHandler != nil {
+		pool.duplicateHandler(ie.ID, relays)
+	}
+	return seen
+}
+
+// CountMany aggregates count results from multiple relays using HyperLogLog, see CountResult.Best.
 func (pool *SimplePool) CountMany(
 	ctx context.Context,
 	urls []string,
 	filter Filter,
 	opts []SubscriptionOption,
 ) int {
+	return int(pool.CountManyDetailed(ctx, urls, filter, false, opts...).Best())
+}
+
+// RelayCount is the answer of a single relay to a COUNT request.
+type RelayCount struct {
+	URL            string
+	Count          int64
+	Approximate    bool
+	HasHyperLogLog bool
+}
+
+// CountResult holds the aggregated results of a COUNT request sent to multiple relays.
+type CountResult struct {
+	// HyperLogLog is the estimate obtained by merging the NIP-45 HyperLogLog registers of all relays that sent them.
+	HyperLogLog int64
+
+	// Max and Sum are computed from the plain counts of all relays that answered. Sum will probably
+	// count the same events more than once.
+	Max int64
+	Sum int64
+
+	// Approximate is true if any relay said its count was approximate.
+	Approximate bool
+
+	// Fallback is the number of distinct pubkeys found in the events fetched from the relays,
+	// only set when UsedFallback is true.
+	Fallback     int64
+	UsedFallback bool
+
+	// Relays lists the answers of all relays that answered.
+	Relays []RelayCount
+}
+
+// Best returns the best count we have: the largest of the HyperLogLog estimate (if any relay sent
+// registers) and the plain counts, otherwise the client-side fallback count.
+func (cr CountResult) Best() int64 {
+	if len(cr.Relays) > 0 {
+		// a relay may know about more events than all the others that sent registers
+		return max(cr.HyperLogLog, cr.Max)
+	}
+	return cr.Fallback
+}
+
+// CountManyDetailed is like CountMany, but returns the full CountResult.
+// If fallback is true and no relay answers the COUNT, events matching the filter are fetched
+// instead and the distinct pubkeys among them are counted.
+func (pool *SimplePool) CountManyDetailed(
+	ctx context.Context,
+	urls []string,
+	filter Filter,
+	fallback bool,
+	opts ...SubscriptionOption,
+) CountResult {
 	hll := hyperloglog.New(0) // offset is irrelevant here, so we just pass 0
+	res := CountResult{}
+	mu := sync.Mutex{}
 
 	wg := sync.WaitGroup{}
 	wg.Add(len(urls))
 	for _, url := range urls {
 		go func(nm string) {
 			defer wg.Done()
-			relay, err := pool.EnsureRelay(url)
+			relay, err := pool.EnsureRelay(nm)
 			if err != nil {
 				return
 			}
 			ce, err := relay.countInternal(ctx, Filters{filter}, opts...)
-			if err != nil {
+			if err != nil || ce.Count == nil {
 				return
 			}
-			if len(ce.HyperLogLog) != 256 {
-				return
+
+			rc := RelayCount{URL: relay.URL, Count: *ce.Count, Approximate: ce.Approximate}
+
+			mu.Lock()
+			if len(ce.HyperLogLog) == 256 {
+				hll.MergeRegisters(ce.HyperLogLog)
+				rc.HasHyperLogLog = true
 			}
-			hll.MergeRegisters(ce.HyperLogLog)
+			res.Relays = append(res.Relays, rc)
+			res.Sum += rc.Count
+			res.Max = max(res.Max, rc.Count)
+			res.Approximate = res.Approximate || rc.Approximate
+			mu.Unlock()
 		}(NormalizeURL(url))
 	}
-
 	wg.Wait()
-	return int(hll.Count())
+
+	res.HyperLogLog = int64(hll.Count())
+
+	if fallback && len(res.Relays) == 0 {
+		pubkeys := make(map[string]struct{})
+		for ie := range pool.SubManyEose(ctx, urls, Filters{filter}, opts...) {
+			pubkeys[ie.PubKey] = struct{}{}
+		}
+		res.Fallback = int64(len(pubkeys))
+		res.UsedFallback = true
+	}
+
+	return res
 }
 
 // QuerySingle returns the first event returned by the first relay, cancels everything else.
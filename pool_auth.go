@@ -0,0 +1,115 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/puzpuzpuz/xsync/v3"
+)
+
+// WithRelayAuth sets a NIP-42 authentication policy for the pool that takes precedence over WithAuthHandler.
+// The auth state is tracked per relay and reused by all subscriptions and publishes, so each challenge is
+// only answered once. Publishes rejected with "auth-required:" are retried after authenticating.
+type WithRelayAuth struct {
+	// Signer returns the identity to use when authenticating to the given relay URL, or nil
+	// if we shouldn't authenticate to it.
+	Signer func(relayURL string) Signer
+
+	// Preemptive returns true for relays we should authenticate to as soon as they send a challenge,
+	// instead of waiting for a "auth-required:" rejection. Can be nil.
+	Preemptive func(relayURL string) bool
+}
+
+func (h WithRelayAuth) ApplyPoolOption(pool *SimplePool) {
+	pool.relayAuth = &h
+	pool.authStates = xsync.NewMapOf[string, *relayAuthState]()
+}
+
+var _ PoolOption = WithRelayAuth{}
+
+type relayAuthState struct {
+	mu        sync.Mutex
+	challenge string // the last challenge we've successfully answered
+	pubkey    string
+}
+
+// AuthenticatedAs returns the pubkey we're currently authenticated with on the given relay, if any.
+// It only works with WithRelayAuth.
+func (pool *SimplePool) AuthenticatedAs(url string) (pubkey string, ok bool) {
+	if pool.authStates == nil {
+		return "", false
+	}
+	state, ok := pool.authStates.Load(NormalizeURL(url))
+	if !ok {
+		return "", false
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.pubkey, state.pubkey != ""
+}
+
+// canAuth tells if we have any means of performing auth on the given relay.
+func (pool *SimplePool) canAuth(relay *Relay) bool {
+	if pool.relayAuth != nil {
+		return pool.relayAuth.Signer(relay.URL) != nil
+	}
+	return pool.authHandler != nil
+}
+
+// authenticate performs NIP-42 auth on the relay using either WithRelayAuth or WithAuthHandler.
+func (pool *SimplePool) authenticate(ctx context.Context, relay *Relay) error {
+	if pool.relayAuth == nil {
+		if pool.authHandler == nil {
+			return fmt.Errorf("no auth handler")
+		}
+		return relay.Auth(ctx, func(event *Event) error {
+			return pool.authHandler(ctx, RelayEvent{Event: event, Relay: relay})
+		})
+	}
+
+	signer := pool.relayAuth.Signer(relay.URL)
+	if signer == nil {
+		return fmt.Errorf("no signer for %s", relay.URL)
+	}
+
+	state, _ := pool.authStates.LoadOrCompute(relay.URL, func() *relayAuthState { return &relayAuthState{} })
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	challenge := relay.lastChallenge()
+	if challenge != "" && state.challenge == challenge {
+		// someone else has already answered this challenge
+		return nil
+	}
+
+	var pubkey string
+	err := relay.auth(ctx, challenge, func(event *Event) error {
+		if err := signer.SignEvent(ctx, event); err != nil {
+			return err
+		}
+		pubkey = event.PubKey
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	state.challenge = challenge
+	state.pubkey = pubkey
+	return nil
+}
+
+// setPreemptiveAuth makes a relay that isn't connected yet authenticate as soon as a challenge arrives,
+// if the policy says so for it.
+func (pool *SimplePool) setPreemptiveAuth(relay *Relay) {
+	if pool.relayAuth == nil || pool.relayAuth.Preemptive == nil || !pool.relayAuth.Preemptive(relay.URL) {
+		return
+	}
+
+	WithAuthChallengeHandler(func(string) {
+		if err := pool.authenticate(pool.Context, relay); err != nil {
+			debugLogf("failed to authenticate to %s: %s", relay.URL, err)
+		}
+	}).ApplyRelayOption(relay)
+}
@@ -0,0 +1,307 @@
+package nostr
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"hash/maphash"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v3"
+)
+
+// Deduplicator is used by the pool to drop events that were already received from another relay
+// in the same subscription. Implementations must be safe for concurrent use.
+type Deduplicator interface {
+	// Seen records that the event with the given id was received from relay and reports whether
+	// it had been received before. When it had, relays lists the relays it is known to have
+	// come from so far (including this one) -- strategies that don't keep track of that
+	// may return only the current relay.
+	Seen(id string, relay string) (seen bool, relays []string)
+}
+
+// quietDeduplicator is implemented by deduplicators that can skip copying the list of relays when
+// nobody is going to look at it.
+type quietDeduplicator interface {
+	seen(id string, relay string) bool
+}
+
+// seenBefore is like dedup.Seen when we don't care about the relays.
+func seenBefore(dedup Deduplicator, id string, relay string) bool {
+	if quiet, ok := dedup.(quietDeduplicator); ok {
+		return quiet.seen(id, relay)
+	}
+	seen, _ := dedup.Seen(id, relay)
+	return seen
+}
+
+var (
+	_ quietDeduplicator = (*LRUDeduplicator)(nil)
+	_ quietDeduplicator = (*TimeWindowDeduplicator)(nil)
+
+	_ Deduplicator = (*LRUDeduplicator)(nil)
+	_ Deduplicator = (*TimeWindowDeduplicator)(nil)
+	_ Deduplicator = (*BloomDeduplicator)(nil)
+	_ Deduplicator = (*mapDeduplicator)(nil)
+)
+
+// LRUDeduplicator remembers up to a fixed number of event ids, forgetting the least recently seen first.
+type LRUDeduplicator struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[ID]*list.Element
+}
+
+type lruEntry struct {
+	id     ID
+	relays []string
+}
+
+// NewLRUDeduplicator returns a Deduplicator that never holds more than size ids in memory.
+func NewLRUDeduplicator(size int) *LRUDeduplicator {
+	return &LRUDeduplicator{
+		size:    max(size, 1),
+		order:   list.New(),
+		entries: make(map[ID]*list.Element, min(size, 1024)),
+	}
+}
+
+func (d *LRUDeduplicator) Seen(id string, relay string) (bool, []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if entry := d.see(id, relay); entry != nil {
+		return true, slices.Clone(entry.relays)
+	}
+	return false, nil
+}
+
+func (d *LRUDeduplicator) seen(id string, relay string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.see(id, relay) != nil
+}
+
+// see records the id, returning its entry if it was already there.
+func (d *LRUDeduplicator) see(id string, relay string) *lruEntry {
+	key := dedupKey(id)
+
+	if el, ok := d.entries[key]; ok {
+		entry := el.Value.(*lruEntry)
+		if !slices.Contains(entry.relays, relay) {
+			entry.relays = append(entry.relays, relay)
+		}
+		d.order.MoveToFront(el)
+		return entry
+	}
+
+	if d.order.Len() >= d.size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.entries, oldest.Value.(*lruEntry).id)
+	}
+	d.entries[key] = d.order.PushFront(&lruEntry{id: key, relays: []string{relay}})
+	return nil
+}
+
+// TimeWindowDeduplicator remembers event ids for a fixed amount of time after they were last seen.
+type TimeWindowDeduplicator struct {
+	mu        sync.Mutex
+	window    time.Duration
+	lastPrune time.Time
+	entries   map[ID]*windowEntry
+
+	// set for the default SubMany deduplicator, nothing is forgotten until gotEOSE is called
+	untilEOSE bool
+}
+
+type windowEntry struct {
+	lastSeen time.Time
+	relays   []string
+}
+
+// NewTimeWindowDeduplicator returns a Deduplicator that forgets ids that haven't been
+// seen again for longer than window. Pruning happens lazily, at most once per window.
+func NewTimeWindowDeduplicator(window time.Duration) *TimeWindowDeduplicator {
+	return &TimeWindowDeduplicator{
+		window:    window,
+		lastPrune: time.Now(),
+		entries:   make(map[ID]*windowEntry),
+	}
+}
+
+// newEOSETimeWindowDeduplicator returns the default deduplicator for subscriptions that stay open, which
+// remembers all ids until the stored events are received and then works like a TimeWindowDeduplicator.
+func newEOSETimeWindowDeduplicator(window time.Duration) *TimeWindowDeduplicator {
+	d := NewTimeWindowDeduplicator(window)
+	d.untilEOSE = true
+	return d
+}
+
+// gotEOSE makes the deduplicator start forgetting ids, see newEOSETimeWindowDeduplicator.
+func (d *TimeWindowDeduplicator) gotEOSE() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.untilEOSE {
+		d.untilEOSE = false
+		d.lastPrune = time.Now()
+	}
+}
+
+func (d *TimeWindowDeduplicator) Seen(id string, relay string) (bool, []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if entry := d.see(id, relay); entry != nil {
+		return true, slices.Clone(entry.relays)
+	}
+	return false, nil
+}
+
+func (d *TimeWindowDeduplicator) seen(id string, relay string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.see(id, relay) != nil
+}
+
+// see records the id, returning its entry if it was already seen within the window.
+func (d *TimeWindowDeduplicator) see(id string, relay string) *windowEntry {
+	key := dedupKey(id)
+
+	now := time.Now()
+	if !d.untilEOSE && now.Sub(d.lastPrune) > d.window {
+		for key, entry := range d.entries {
+			if now.Sub(entry.lastSeen) > d.window {
+				delete(d.entries, key)
+			}
+		}
+		d.lastPrune = now
+	}
+
+	if entry, ok := d.entries[key]; ok && (d.untilEOSE || now.Sub(entry.lastSeen) <= d.window) {
+		entry.lastSeen = now
+		if !slices.Contains(entry.relays, relay) {
+			entry.relays = append(entry.relays, relay)
+		}
+		return entry
+	}
+
+	d.entries[key] = &windowEntry{lastSeen: now, relays: []string{relay}}
+	return nil
+}
+
+// BloomDeduplicator uses two Bloom filters that are rotated whenever the current one is full,
+// so memory usage is fixed regardless of how many events pass through it. Like any Bloom filter
+// it may report false positives (dropping an event that wasn't actually seen) at roughly the
+// configured rate, and it doesn't keep track of relays.
+type BloomDeduplicator struct {
+	mu       sync.Mutex
+	capacity int
+	k        int
+	m        uint64
+	seeds    [2]maphash.Seed
+	count    int
+	current  []uint64
+	previous []uint64
+}
+
+// NewBloomDeduplicator returns a Deduplicator that guarantees to remember at least the last
+// capacity ids (and at most 2*capacity) with the given falsePositiveRate.
+func NewBloomDeduplicator(capacity int, falsePositiveRate float64) *BloomDeduplicator {
+	capacity = max(capacity, 1)
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.001
+	}
+
+	// standard optimal bloom filter parameters, the rate is halved because we check two filters
+	bits := math.Ceil(-float64(capacity) * math.Log(falsePositiveRate/2) / (math.Ln2 * math.Ln2))
+	words := uint64(bits+63) / 64
+	k := int(math.Round(bits / float64(capacity) * math.Ln2))
+
+	return &BloomDeduplicator{
+		capacity: capacity,
+		k:        max(k, 1),
+		m:        words * 64,
+		seeds:    [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+		current:  make([]uint64, words),
+		previous: make([]uint64, words),
+	}
+}
+
+func (d *BloomDeduplicator) Seen(id string, relay string) (bool, []string) {
+	// double hashing as in Kirsch-Mitzenmacher
+	key := dedupKey(id)
+	h1 := maphash.Bytes(d.seeds[0], key[:])
+	h2 := maphash.Bytes(d.seeds[1], key[:]) | 1
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	inCurrent, inPrevious := true, true
+	for i := range uint64(d.k) {
+		bit := (h1 + i*h2) % d.m
+		if d.current[bit/64]&(1<<(bit%64)) == 0 {
+			inCurrent = false
+		}
+		if d.previous[bit/64]&(1<<(bit%64)) == 0 {
+			inPrevious = false
+		}
+	}
+
+	if inCurrent {
+		return true, []string{relay}
+	}
+
+	if d.count >= d.capacity {
+		d.previous, d.current = d.current, d.previous
+		clear(d.current)
+		d.count = 0
+	}
+	for i := range uint64(d.k) {
+		bit := (h1 + i*h2) % d.m
+		d.current[bit/64] |= 1 << (bit % 64)
+	}
+	d.count++
+
+	if inPrevious {
+		return true, []string{relay}
+	}
+	return false, nil
+}
+
+// mapDeduplicator remembers everything, it is used by default in SubManyEose.
+type mapDeduplicator struct {
+	seen *xsync.MapOf[ID, []string]
+}
+
+func newMapDeduplicator() *mapDeduplicator {
+	return &mapDeduplicator{seen: xsync.NewMapOf[ID, []string]()}
+}
+
+func (d *mapDeduplicator) Seen(id string, relay string) (seen bool, relays []string) {
+	d.seen.Compute(dedupKey(id), func(old []string, loaded bool) ([]string, bool) {
+		seen = loaded
+		if !slices.Contains(old, relay) {
+			old = append(slices.Clip(old), relay)
+		}
+		relays = old
+		return old, false
+	})
+	if !seen {
+		return false, nil
+	}
+	return true, relays
+}
+
+// dedupKey turns an event id into the binary form used as a key by the deduplicators above.
+// Malformed ids (which should never reach here, but relays can send anything) are hashed instead.
+func dedupKey(id string) ID {
+	key, err := ParseID(id)
+	if err != nil {
+		return sha256.Sum256([]byte(id))
+	}
+	return key
+}
@@ -0,0 +1,88 @@
+package nostr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUDeduplicator(t *testing.T) {
+	d := NewLRUDeduplicator(2)
+
+	seen, _ := d.Seen("a", "wss://one")
+	require.False(t, seen)
+	seen, relays := d.Seen("a", "wss://two")
+	require.True(t, seen)
+	require.Equal(t, []string{"wss://one", "wss://two"}, relays)
+
+	d.Seen("b", "wss://one")
+	d.Seen("c", "wss://one") // evicts "a"
+
+	seen, _ = d.Seen("a", "wss://one")
+	require.False(t, seen)
+	seen, _ = d.Seen("c", "wss://two")
+	require.True(t, seen)
+}
+
+func TestTimeWindowDeduplicator(t *testing.T) {
+	d := NewTimeWindowDeduplicator(50 * time.Millisecond)
+
+	seen, _ := d.Seen("a", "wss://one")
+	require.False(t, seen)
+	seen, relays := d.Seen("a", "wss://two")
+	require.True(t, seen)
+	require.Equal(t, []string{"wss://one", "wss://two"}, relays)
+
+	time.Sleep(60 * time.Millisecond)
+	seen, _ = d.Seen("a", "wss://one")
+	require.False(t, seen)
+
+	// the default for SubMany doesn't forget anything before EOSE
+	d = newEOSETimeWindowDeduplicator(50 * time.Millisecond)
+	require.False(t, seenBefore(d, "a", "wss://one"))
+	time.Sleep(60 * time.Millisecond)
+	require.True(t, seenBefore(d, "a", "wss://two"))
+	d.gotEOSE()
+	seen, relays = d.Seen("a", "wss://three")
+	require.True(t, seen)
+	require.Equal(t, []string{"wss://one", "wss://two", "wss://three"}, relays)
+	time.Sleep(60 * time.Millisecond)
+	require.False(t, seenBefore(d, "a", "wss://one"))
+}
+
+func TestBloomDeduplicator(t *testing.T) {
+	d := NewBloomDeduplicator(1000, 0.0001)
+
+	for i := range 1000 {
+		seen, _ := d.Seen(fmt.Sprintf("id%d", i), "wss://one")
+		require.False(t, seen)
+	}
+	for i := range 1000 {
+		seen, _ := d.Seen(fmt.Sprintf("id%d", i), "wss://two")
+		require.True(t, seen)
+	}
+
+	// after two rotations the first ids are forgotten
+	for i := range 2000 {
+		d.Seen(fmt.Sprintf("other%d", i), "wss://one")
+	}
+	falsePositives := 0
+	for i := range 1000 {
+		if seen, _ := d.Seen(fmt.Sprintf("id%d", i), "wss://one"); seen {
+			falsePositives++
+		}
+	}
+	require.Less(t, falsePositives, 10)
+}
+
+func TestMapDeduplicator(t *testing.T) {
+	d := newMapDeduplicator()
+
+	seen, _ := d.Seen("a", "wss://one")
+	require.False(t, seen)
+	seen, relays := d.Seen("a", "wss://two")
+	require.True(t, seen)
+	require.Equal(t, []string{"wss://one", "wss://two"}, relays)
+}
@@ -0,0 +1,97 @@
+package nostr
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// EventPolicy is run on every event received by the pool subscriptions before it is passed to the
+// event middleware, deduplicated or delivered. It may return a modified RelayEvent (to rewrite or
+// annotate it) or an error, in which case the event is dropped and the error is used as the reason.
+// Rewritten events are not saved to the pool's store (see WithRelayStore), the original is. Events served
+// from the store go through the policies too, with a nil Relay.
+type EventPolicy func(ie RelayEvent) (RelayEvent, error)
+
+// WithEventPolicy adds a policy to the pool. More than one can be passed, they will run in order
+// and the first to reject an event stops the chain.
+func WithEventPolicy(policies ...EventPolicy) withEventPolicyOpt { return withEventPolicyOpt(policies) }
+
+type withEventPolicyOpt []EventPolicy
+
+func (h withEventPolicyOpt) ApplyPoolOption(pool *SimplePool) {
+	pool.eventPolicies = append(pool.eventPolicies, h...)
+}
+
+// WithRejectedEventHandler is a function that will be called with every event dropped by an EventPolicy,
+// along with the reason.
+type WithRejectedEventHandler func(ie RelayEvent, reason error)
+
+func (h WithRejectedEventHandler) ApplyPoolOption(pool *SimplePool) {
+	pool.rejectedEventHandler = h
+}
+
+var (
+	_ PoolOption = WithEventPolicy()
+	_ PoolOption = (WithRejectedEventHandler)(nil)
+)
+
+// applyEventPolicies runs all the policies on the event, returns false if it must be dropped.
+func (pool *SimplePool) applyEventPolicies(ie RelayEvent) (RelayEvent, bool) {
+	for _, policy := range pool.eventPolicies {
+		res, err := policy(ie)
+		if err != nil {
+			if pool.rejectedEventHandler != nil {
+				pool.rejectedEventHandler(ie, err)
+			}
+			return ie, false
+		}
+		ie = res
+	}
+	return ie, true
+}
+
+// PolicyAllowKinds rejects all events whose kind is not in the list.
+func PolicyAllowKinds(kinds ...int) EventPolicy {
+	return func(ie RelayEvent) (RelayEvent, error) {
+		if !slices.Contains(kinds, ie.Kind) {
+			return ie, fmt.Errorf("kind %d not allowed", ie.Kind)
+		}
+		return ie, nil
+	}
+}
+
+// PolicyMutePubkeys rejects all events authored by the given pubkeys.
+func PolicyMutePubkeys(pubkeys ...string) EventPolicy {
+	muted := make(map[string]struct{}, len(pubkeys))
+	for _, pk := range pubkeys {
+		muted[pk] = struct{}{}
+	}
+	return func(ie RelayEvent) (RelayEvent, error) {
+		if _, ok := muted[ie.PubKey]; ok {
+			return ie, fmt.Errorf("author %s is muted", ie.PubKey)
+		}
+		return ie, nil
+	}
+}
+
+// PolicyRejectFuture rejects events with a created_at more than tolerance in the future.
+func PolicyRejectFuture(tolerance time.Duration) EventPolicy {
+	return func(ie RelayEvent) (RelayEvent, error) {
+		if limit := Timestamp(time.Now().Add(tolerance).Unix()); ie.CreatedAt > limit {
+			return ie, fmt.Errorf("created_at %d is in the future", ie.CreatedAt)
+		}
+		return ie, nil
+	}
+}
+
+// PolicyRejectExpired rejects events with a NIP-40 "expiration" tag in the past.
+func PolicyRejectExpired() EventPolicy {
+	return func(ie RelayEvent) (RelayEvent, error) {
+		if ie.IsExpired() {
+			exp, _ := ie.Expiration()
+			return ie, fmt.Errorf("expired at %d", exp)
+		}
+		return ie, nil
+	}
+}
@@ -0,0 +1,65 @@
+package nostr
+
+import "context"
+
+// IsNewerVersion reports whether a should replace b: it is newer or, when both were created
+// at the same second, has the lowest id, as specified by NIP-01.
+func IsNewerVersion(a, b *Event) bool {
+	if a.CreatedAt == b.CreatedAt {
+		return a.ID < b.ID
+	}
+	return a.CreatedAt > b.CreatedAt
+}
+
+// SubManyEoseReplaceable is like SubManyEose, but replaceable and addressable events are only
+// emitted when they are the newest version seen so far for their Address, so older versions received
+// from other relays are dropped and newer ones received later come as updates.
+// Non-replaceable events are emitted normally.
+func (pool *SimplePool) SubManyEoseReplaceable(
+	ctx context.Context,
+	urls []string,
+	filters Filters,
+	opts ...SubscriptionOption,
+) chan RelayEvent {
+	events := make(chan RelayEvent)
+	latest := make(map[Address]*Event)
+
+	go func() {
+		defer close(events)
+
+		for ie := range pool.subManyEose(ctx, urls, filters, true, opts) {
+			if addr, ok := ie.Event.Address(); ok {
+				if current, exists := latest[addr]; exists && !IsNewerVersion(ie.Event, current) {
+					continue
+				}
+				latest[addr] = ie.Event
+			}
+
+			select {
+			case events <- ie:
+			case <-ctx.Done():
+				// keep draining so the underlying subscriptions can finish
+			}
+		}
+	}()
+
+	return events
+}
+
+// FetchManyReplaceable queries the given relays and returns only the newest version of each
+// replaceable or addressable event found, keyed by their Address. Other events are ignored.
+func (pool *SimplePool) FetchManyReplaceable(
+	ctx context.Context,
+	urls []string,
+	filters Filters,
+	opts ...SubscriptionOption,
+) map[Address]*Event {
+	results := make(map[Address]*Event)
+	for ie := range pool.SubManyEoseReplaceable(ctx, urls, filters, opts...) {
+		if addr, ok := ie.Event.Address(); ok {
+			results[addr] = ie.Event
+		}
+	}
+
+	return results
+}
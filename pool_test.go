@@ -0,0 +1,314 @@
+package nostr
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// memoryStore is a minimal RelayStore used for testing the pool. other packages should use
+// test_common.MemoryStore, which this package can't import without an import cycle.
+type memoryStore struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (s *memoryStore) Publish(_ context.Context, evt Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, &evt)
+	return nil
+}
+
+func (s *memoryStore) QueryEvents(ctx context.Context, filter Filter) (chan *Event, error) {
+	res, _ := s.QuerySync(ctx, filter)
+	ch := make(chan *Event, len(res))
+	for _, evt := range res {
+		ch <- evt
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (s *memoryStore) QuerySync(_ context.Context, filter Filter) ([]*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.DeleteFunc(slices.Clone(s.events), func(evt *Event) bool { return !filter.Matches(evt) }), nil
+}
+
+func TestPoolStoreFirst(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{}
+	store.Publish(ctx, Event{ID: "aa", Kind: 1, CreatedAt: 100})
+	store.Publish(ctx, Event{ID: "bb", Kind: 1, CreatedAt: 200})
+
+	pool := NewSimplePool(ctx, WithRelayStore(store))
+
+	stored, narrowed := pool.queryStore(ctx, Filters{{Kinds: []int{1}}, {IDs: []string{"aa"}}, {IDs: []string{"aa", "cc"}}})
+	require.Len(t, stored, 4)
+	require.Len(t, narrowed, 2)
+	require.Equal(t, Timestamp(200), *narrowed[0].Since)
+	require.Equal(t, []string{"cc"}, narrowed[1].IDs)
+
+	// everything is in the store, so no relay is needed
+	evt := pool.QuerySingle(ctx, []string{"wss://relay.invalid"}, Filter{IDs: []string{"bb"}})
+	require.NotNil(t, evt)
+	require.Nil(t, evt.Relay)
+	require.Equal(t, "bb", evt.ID)
+
+	pool.saveToStore(ctx, pool.copyForStore(&Event{ID: "dd", Kind: 20001}))
+	require.Len(t, store.events, 2, "ephemeral events must not be saved")
+	pool.saveToStore(ctx, pool.copyForStore(&Event{ID: "dd", Kind: 1}))
+	require.Len(t, store.events, 3)
+}
+
+func TestIsNewerVersion(t *testing.T) {
+	profile := &Event{ID: "bb", PubKey: "pk", Kind: KindProfileMetadata, CreatedAt: 100}
+	require.True(t, IsNewerVersion(&Event{ID: "cc", CreatedAt: 101}, profile))
+	require.False(t, IsNewerVersion(&Event{ID: "aa", CreatedAt: 99}, profile))
+	require.True(t, IsNewerVersion(&Event{ID: "aa", CreatedAt: 100}, profile), "ties are broken by lowest id")
+	require.False(t, IsNewerVersion(&Event{ID: "cc", CreatedAt: 100}, profile))
+}
+
+func TestSubManyEoseReplaceable(t *testing.T) {
+	sk := GeneratePrivateKey()
+	sign := func(evt *Event) *Event {
+		require.NoError(t, evt.Sign(sk))
+		return evt
+	}
+	old := sign(&Event{Kind: KindProfileMetadata, CreatedAt: 100, Content: "old"})
+	current := sign(&Event{Kind: KindProfileMetadata, CreatedAt: 200, Content: "current"})
+	article := sign(&Event{Kind: KindArticle, CreatedAt: 100, Tags: Tags{{"d", "a"}}})
+	note := sign(&Event{Kind: KindTextNote, CreatedAt: 100})
+
+	// each relay has a different version of the profile
+	relayWith := func(events ...*Event) string {
+		ws := newWebsocketServer(func(conn *websocket.Conn) {
+			for {
+				var raw []stdjson.RawMessage
+				if err := websocket.JSON.Receive(conn, &raw); err != nil {
+					return
+				}
+				var typ, id string
+				json.Unmarshal(raw[0], &typ)
+				if typ != "REQ" {
+					continue
+				}
+				json.Unmarshal(raw[1], &id)
+				for _, evt := range events {
+					websocket.JSON.Send(conn, []any{"EVENT", id, evt})
+				}
+				websocket.JSON.Send(conn, []any{"EOSE", id})
+			}
+		})
+		t.Cleanup(ws.Close)
+		return ws.URL
+	}
+	urls := []string{relayWith(old, note), relayWith(current, article, note)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pool := NewSimplePool(ctx)
+
+	latest := make(map[Address]*Event)
+	notes := 0
+	for ie := range pool.SubManyEoseReplaceable(ctx, urls, Filters{{Authors: []string{note.PubKey}}}) {
+		addr, ok := ie.Event.Address()
+		if !ok {
+			notes++
+			continue
+		}
+		if prev, exists := latest[addr]; exists {
+			require.True(t, IsNewerVersion(ie.Event, prev), "only newer versions come as updates")
+		}
+		latest[addr] = ie.Event
+	}
+	require.Equal(t, 1, notes)
+	require.Len(t, latest, 2)
+	profileAddr, _ := current.Address()
+	require.Equal(t, current.ID, latest[profileAddr].ID)
+
+	results := pool.FetchManyReplaceable(ctx, urls, Filters{{Authors: []string{note.PubKey}}})
+	require.Len(t, results, 2)
+	require.Equal(t, "current", results[profileAddr].Content)
+	articleAddr, _ := article.Address()
+	require.Equal(t, article.ID, results[articleAddr].ID)
+}
+
+func TestPoolEventPolicies(t *testing.T) {
+	var rejected []string
+	pool := NewSimplePool(context.Background(),
+		WithEventPolicy(
+			PolicyAllowKinds(KindTextNote, KindReaction),
+			PolicyMutePubkeys("muted"),
+			PolicyRejectFuture(time.Minute),
+			PolicyRejectExpired(),
+			func(ie RelayEvent) (RelayEvent, error) {
+				ie.Event.Content = "rewritten"
+				return ie, nil
+			},
+		),
+		WithRejectedEventHandler(func(ie RelayEvent, reason error) {
+			rejected = append(rejected, ie.ID)
+		}),
+	)
+
+	relay := &Relay{URL: "wss://relay.example"}
+	for _, evt := range []*Event{
+		{ID: "kind", Kind: KindRepost, CreatedAt: Now()},
+		{ID: "muted", Kind: KindTextNote, PubKey: "muted", CreatedAt: Now()},
+		{ID: "future", Kind: KindTextNote, CreatedAt: Now() + 3600},
+		{ID: "expired", Kind: KindTextNote, CreatedAt: Now(), Tags: Tags{{"expiration", "1000"}}},
+	} {
+		_, ok := pool.applyEventPolicies(RelayEvent{Event: evt, Relay: relay})
+		require.False(t, ok)
+	}
+	require.Equal(t, []string{"kind", "muted", "future", "expired"}, rejected)
+
+	ie, ok := pool.applyEventPolicies(RelayEvent{Event: &Event{ID: "good", Kind: KindReaction, CreatedAt: Now()}, Relay: relay})
+	require.True(t, ok)
+	require.Equal(t, "rewritten", ie.Content)
+}
+
+func TestPoolStoreSavesOriginalEvent(t *testing.T) {
+	evt := &Event{Kind: KindTextNote, CreatedAt: Now(), Content: "original", Tags: Tags{{"t", "original"}}}
+	require.NoError(t, evt.Sign(GeneratePrivateKey()))
+	muted := &Event{Kind: KindTextNote, CreatedAt: Now(), Content: "muted"}
+	require.NoError(t, muted.Sign(GeneratePrivateKey()))
+
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		var raw []stdjson.RawMessage
+		if err := websocket.JSON.Receive(conn, &raw); err != nil {
+			return
+		}
+		var id string
+		json.Unmarshal(raw[1], &id)
+		websocket.JSON.Send(conn, []any{"EVENT", id, evt})
+		websocket.JSON.Send(conn, []any{"EVENT", id, muted})
+		websocket.JSON.Send(conn, []any{"EOSE", id})
+		io.ReadAll(conn)
+	})
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	store := &memoryStore{}
+	pool := NewSimplePool(ctx,
+		WithRelayStore(store),
+		WithEventPolicy(
+			PolicyMutePubkeys(muted.PubKey),
+			func(ie RelayEvent) (RelayEvent, error) {
+				ie.Event.Content = "rewritten"
+				if len(ie.Tags) > 0 {
+					ie.Tags[0][1] = "rewritten" // in place
+				}
+				return ie, nil
+			},
+		),
+	)
+
+	var received []RelayEvent
+	for ie := range pool.SubManyEose(ctx, []string{ws.URL}, Filters{{Kinds: []int{KindTextNote}}}) {
+		received = append(received, ie)
+	}
+	require.Len(t, received, 1)
+	require.Equal(t, "rewritten", received[0].Content)
+
+	// the store has it as it came from the relay, and rejected events aren't saved
+	require.Len(t, store.events, 1)
+	stored := store.events[0]
+	require.Equal(t, "original", stored.Content)
+	require.Equal(t, "original", stored.Tags[0][1])
+	require.True(t, stored.CheckID())
+	ok, err := stored.CheckSignature()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// events served from the store go through the policies too
+	require.NoError(t, store.Publish(ctx, *muted))
+	received = nil
+	for ie := range pool.SubManyEose(ctx, []string{ws.URL}, Filters{{Kinds: []int{KindTextNote}}}) {
+		if ie.Relay == nil {
+			received = append(received, ie)
+		}
+	}
+	require.Len(t, received, 1)
+	require.Equal(t, evt.ID, received[0].ID)
+	require.Equal(t, "rewritten", received[0].Content)
+}
+
+type testSigner string
+
+func (sk testSigner) GetPublicKey(context.Context) (string, error)  { return GetPublicKey(string(sk)) }
+func (sk testSigner) SignEvent(_ context.Context, evt *Event) error { return evt.Sign(string(sk)) }
+
+func TestPoolPreemptiveAuth(t *testing.T) {
+	priv, pub := makeKeyPair(t)
+
+	authed := make(chan string, 1)
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		websocket.JSON.Send(conn, []any{"AUTH", "chachalenge"})
+
+		var raw []stdjson.RawMessage
+		require.NoError(t, websocket.JSON.Receive(conn, &raw))
+		var typ string
+		json.Unmarshal(raw[0], &typ)
+		require.Equal(t, "AUTH", typ)
+		var evt Event
+		json.Unmarshal(raw[1], &evt)
+		require.NotNil(t, evt.Tags.GetFirst([]string{"challenge", "chachalenge"}))
+
+		websocket.JSON.Send(conn, []any{"OK", evt.ID, true, ""})
+		authed <- evt.PubKey
+
+		// keep the connection open
+		websocket.JSON.Receive(conn, &raw)
+	})
+	defer ws.Close()
+
+	pool := NewSimplePool(context.Background(), WithRelayAuth{
+		Signer:     func(string) Signer { return testSigner(priv) },
+		Preemptive: func(string) bool { return true },
+	})
+	_, err := pool.EnsureRelay(ws.URL)
+	require.NoError(t, err)
+
+	select {
+	case pk := <-authed:
+		require.Equal(t, pub, pk)
+	case <-time.After(2 * time.Second):
+		t.Fatal("relay saw no AUTH")
+	}
+
+	require.Eventually(t, func() bool {
+		pk, ok := pool.AuthenticatedAs(ws.URL)
+		return ok && pk == pub
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCountResultBest(t *testing.T) {
+	require.Equal(t, int64(0), CountResult{}.Best())
+	require.Equal(t, int64(7), CountResult{Fallback: 7, UsedFallback: true}.Best())
+	require.Equal(t, int64(12), CountResult{
+		Max:    12,
+		Sum:    20,
+		Relays: []RelayCount{{URL: "wss://a", Count: 12}, {URL: "wss://b", Count: 8}},
+	}.Best())
+	require.Equal(t, int64(15), CountResult{
+		HyperLogLog: 15,
+		Max:         12,
+		Relays:      []RelayCount{{URL: "wss://a", Count: 12}, {URL: "wss://b", Count: 8, HasHyperLogLog: true}},
+	}.Best())
+	require.Equal(t, int64(1000), CountResult{
+		HyperLogLog: 10,
+		Max:         1000,
+		Relays:      []RelayCount{{URL: "wss://a", Count: 1000}, {URL: "wss://b", Count: 10, HasHyperLogLog: true}},
+	}.Best())
+}
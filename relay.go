@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -23,7 +24,8 @@ type Status int
 var subscriptionIDCounter atomic.Int64
 
 type Relay struct {
-	closeMutex sync.Mutex
+	closeMutex     sync.Mutex
+	challengeMutex sync.Mutex
 
 	URL           string
 	RequestHeader http.Header // e.g. for origin header
@@ -35,7 +37,8 @@ type Relay struct {
 	connectionContext       context.Context // will be canceled when the connection closes
 	connectionContextCancel context.CancelFunc
 
-	challenge                     string       // NIP-42 challenge, we only keep the last
+	challenge                     string       // NIP-42 challenge, we only keep the last (guarded by challengeMutex)
+	challengeHandler              func(string) // NIP-42 AUTH challenges
 	noticeHandler                 func(string) // NIP-01 NOTICEs
 	customHandler                 func([]byte) // nonstandard unparseable messages
 	okCallbacks                   *xsync.MapOf[string, func(bool, string)]
@@ -45,6 +48,7 @@ type Relay struct {
 	// custom things that aren't often used
 	//
 	signatureChecker func(event *Event) bool // External signature checker. If nil, default is used.
+	keepRawEvents    bool                    // Received events keep their original JSON, see WithRawEvents().
 }
 
 type writeRequest struct {
@@ -92,6 +96,8 @@ var (
 	_ RelayOption = (WithNoticeHandler)(nil)
 	_ RelayOption = (WithCustomHandler)(nil)
 	_ RelayOption = (WithSignatureChecker)(nil)
+	_ RelayOption = (WithAuthChallengeHandler)(nil)
+	_ RelayOption = withRawEventsOpt{}
 )
 
 // WithSignatureChecker allows to pass a custom function that checks the signature of an event.
@@ -101,6 +107,20 @@ func (sc WithSignatureChecker) ApplyRelayOption(r *Relay) {
 	r.signatureChecker = sc
 }
 
+// WithRawEvents makes all events received from the relay (or from all relays in a pool) keep their original
+// JSON (with any unknown fields), so they can be archived or republished byte by byte, see Event.RawJSON.
+func WithRawEvents() withRawEventsOpt { return withRawEventsOpt{} }
+
+type withRawEventsOpt struct{}
+
+func (_ withRawEventsOpt) ApplyRelayOption(r *Relay) {
+	r.keepRawEvents = true
+}
+
+func (_ withRawEventsOpt) ApplyPoolOption(pool *SimplePool) {
+	pool.keepRawEvents = true
+}
+
 // WithNoticeHandler just takes notices and is expected to do something with them.
 // when not given, defaults to logging the notices.
 type WithNoticeHandler func(notice string)
@@ -109,6 +129,14 @@ func (nh WithNoticeHandler) ApplyRelayOption(r *Relay) {
 	r.noticeHandler = nh
 }
 
+// WithAuthChallengeHandler is called (in a separate goroutine) whenever the relay sends a NIP-42 AUTH challenge,
+// it can be used to authenticate pre-emptively by calling Relay.Auth().
+type WithAuthChallengeHandler func(challenge string)
+
+func (ah WithAuthChallengeHandler) ApplyRelayOption(r *Relay) {
+	r.challengeHandler = ah
+}
+
 // WithCustomHandler must be a function that handles any relay message that couldn't be
 // parsed as a standard envelope.
 type WithCustomHandler func(data []byte)
@@ -227,7 +255,7 @@ func (r *Relay) ConnectWithTLS(ctx context.Context, tlsConfig *tls.Config) error
 
 			message := buf.Bytes()
 			debugLogf("{%s} %v\n", r.URL, message)
-			envelope, err := ParseMessage(message)
+			envelope, err := parseMessage(message, r.keepRawEvents)
 			if err != nil {
 				if r.customHandler != nil {
 					r.customHandler(message)
@@ -247,7 +275,12 @@ func (r *Relay) ConnectWithTLS(ctx context.Context, tlsConfig *tls.Config) error
 				if env.Challenge == nil {
 					continue
 				}
+				r.challengeMutex.Lock()
 				r.challenge = *env.Challenge
+				r.challengeMutex.Unlock()
+				if r.challengeHandler != nil {
+					go r.challengeHandler(*env.Challenge)
+				}
 			case *EventEnvelope:
 				if env.SubscriptionID == nil {
 					continue
@@ -257,17 +290,35 @@ func (r *Relay) ConnectWithTLS(ctx context.Context, tlsConfig *tls.Config) error
 					// InfoLogger.Printf("{%s} no subscription with id '%s'\n", r.URL, *env.SubscriptionID)
 					continue
 				} else {
+					events := make([]*Event, 0, len(env.Events))
 					for _, event := range env.Events {
 						// check if the event matches the desired filter, ignore otherwise
 						if !subscription.match(event) {
 							InfoLogger.Printf("{%s} filter does not match: %v ~ %v\n", r.URL, subscription.Filters, event)
 							continue
 						}
+						if subscription.dropExpired && event.IsExpired() {
+							continue
+						}
+						events = append(events, event)
+					}
+
+					// when many events come together we verify them all at once
+					var badSignatures []int
+					batch := r.signatureChecker == nil && len(events) > 1
+					if batch {
+						_, badSignatures = VerifyBatch(events)
+					}
 
+					for i, event := range events {
 						var signatureOk bool
 						if r.signatureChecker != nil {
 							signatureOk = r.signatureChecker(event)
-						} else {
+						} else if batch {
+							_, found := slices.BinarySearch(badSignatures, i)
+							signatureOk = !found
+						} else if event.CheckID() {
+							// the same checks VerifyBatch does
 							signatureOk, _ = event.CheckSignature()
 						}
 
@@ -394,12 +445,23 @@ func (r *Relay) PublishMany(ctx context.Context, events ...*Event) error {
 
 // Auth sends an "AUTH" command client->relay as in NIP-42 and waits for an OK response.
 func (r *Relay) Auth(ctx context.Context, sign func(event *Event) error) error {
+	return r.auth(ctx, r.lastChallenge(), sign)
+}
+
+// lastChallenge returns the last NIP-42 challenge sent by the relay.
+func (r *Relay) lastChallenge() string {
+	r.challengeMutex.Lock()
+	defer r.challengeMutex.Unlock()
+	return r.challenge
+}
+
+func (r *Relay) auth(ctx context.Context, challenge string, sign func(event *Event) error) error {
 	authEvent := Event{
 		CreatedAt: Now(),
 		Kind:      KindClientAuthentication,
 		Tags: Tags{
 			Tag{"relay", r.URL},
-			Tag{"challenge", r.challenge},
+			Tag{"challenge", challenge},
 		},
 		Content: "",
 	}
@@ -502,6 +564,8 @@ func (r *Relay) PrepareSubscription(ctx context.Context, filters Filters, opts .
 		switch o := opt.(type) {
 		case WithLabel:
 			label = string(o)
+		case WithDropExpired:
+			sub.dropExpired = true
 		}
 	}
 
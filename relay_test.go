@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -275,3 +276,53 @@ func TestPublishMany(t *testing.T) {
 
 	require.True(t, <-ch, "fake relay server saw no events")
 }
+
+func TestReceivedEventsIDCheck(t *testing.T) {
+	t.Parallel()
+
+	sk := GeneratePrivateKey()
+	var good, wrongID, upperID []*Event
+	for i := range 4 {
+		evt := &Event{Kind: KindTextNote, CreatedAt: Now(), Content: "hello " + strconv.Itoa(i)}
+		require.NoError(t, evt.Sign(sk))
+		good = append(good, evt)
+
+		// signature is valid for the contents, but the id isn't theirs
+		bad := *evt
+		bad.ID = strings.Repeat("ab", 32)
+		wrongID = append(wrongID, &bad)
+
+		// the right id, but not in lowercase
+		upper := *evt
+		upper.ID = strings.ToUpper(evt.ID)
+		upperID = append(upperID, &upper)
+	}
+
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		var raw []stdjson.RawMessage
+		require.NoError(t, websocket.JSON.Receive(conn, &raw))
+		var id string
+		require.NoError(t, json.Unmarshal(raw[1], &id))
+
+		// one at a time and many together
+		websocket.JSON.Send(conn, []any{"EVENT", id, wrongID[0]})
+		websocket.JSON.Send(conn, []any{"EVENT", id, good[0]})
+		websocket.JSON.Send(conn, []any{"EVENT", id, good[1], wrongID[1], good[2], wrongID[2], good[3]})
+		websocket.JSON.Send(conn, []any{"EVENT", id, upperID[0]})
+		websocket.JSON.Send(conn, []any{"EVENT", id, upperID[1], upperID[2]})
+		websocket.JSON.Send(conn, []any{"EOSE", id})
+		io.ReadAll(conn)
+	})
+	defer ws.Close()
+
+	rl := mustRelayConnect(t, ws.URL)
+	defer rl.Close()
+
+	events, err := rl.QuerySync(context.Background(), Filter{Kinds: []int{KindTextNote}})
+	require.NoError(t, err)
+	ids := make([]string, len(events))
+	for i, evt := range events {
+		ids[i] = evt.ID
+	}
+	require.ElementsMatch(t, []string{good[0].ID, good[1].ID, good[2].ID, good[3].ID}, ids)
+}
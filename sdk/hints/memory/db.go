@@ -13,8 +13,11 @@ import (
 var _ hints.HintsDB = (*HintDB)(nil)
 
 type HintDB struct {
-	RelayBySerial         []string
+	RelayBySerial []string
+
+	// OrderedRelaysByPubKey only has the pubkeys that aren't valid hex, all others are kept in binary form.
 	OrderedRelaysByPubKey map[string]RelaysForPubKey
+	byPubKey              map[nostr.PubKey]RelaysForPubKey
 
 	sync.Mutex
 }
@@ -22,8 +25,26 @@ type HintDB struct {
 func NewHintDB() *HintDB {
 	return &HintDB{
 		RelayBySerial:         make([]string, 0, 100),
-		OrderedRelaysByPubKey: make(map[string]RelaysForPubKey, 100),
+		OrderedRelaysByPubKey: make(map[string]RelaysForPubKey),
+		byPubKey:              make(map[nostr.PubKey]RelaysForPubKey, 100),
+	}
+}
+
+func (db *HintDB) get(pubkey string) (RelaysForPubKey, bool) {
+	if pk, err := nostr.ParsePubKey(pubkey); err == nil {
+		rfpk, ok := db.byPubKey[pk]
+		return rfpk, ok
 	}
+	rfpk, ok := db.OrderedRelaysByPubKey[pubkey]
+	return rfpk, ok
+}
+
+func (db *HintDB) set(pubkey string, rfpk RelaysForPubKey) {
+	if pk, err := nostr.ParsePubKey(pubkey); err == nil {
+		db.byPubKey[pk] = rfpk
+		return
+	}
+	db.OrderedRelaysByPubKey[pubkey] = rfpk
 }
 
 func (db *HintDB) Save(pubkey string, relay string, key hints.HintKey, ts nostr.Timestamp) {
@@ -41,7 +62,7 @@ func (db *HintDB) Save(pubkey string, relay string, key hints.HintKey, ts nostr.
 	defer db.Unlock()
 	// fmt.Println(" ", relay, "index", relayIndex, "--", "adding", hints.HintKey(key).String(), ts)
 
-	rfpk, _ := db.OrderedRelaysByPubKey[pubkey]
+	rfpk, _ := db.get(pubkey)
 
 	entries := rfpk.Entries
 
@@ -68,7 +89,7 @@ func (db *HintDB) Save(pubkey string, relay string, key hints.HintKey, ts nostr.
 
 	rfpk.Entries = entries
 
-	db.OrderedRelaysByPubKey[pubkey] = rfpk
+	db.set(pubkey, rfpk)
 }
 
 func (db *HintDB) TopN(pubkey string, n int) []string {
@@ -76,7 +97,7 @@ func (db *HintDB) TopN(pubkey string, n int) []string {
 	defer db.Unlock()
 
 	urls := make([]string, 0, n)
-	if rfpk, ok := db.OrderedRelaysByPubKey[pubkey]; ok {
+	if rfpk, ok := db.get(pubkey); ok {
 		// sort everything from scratch
 		slices.SortFunc(rfpk.Entries, func(a, b RelayEntry) int {
 			return int(b.Sum() - a.Sum())
@@ -97,14 +118,21 @@ func (db *HintDB) PrintScores() {
 	defer db.Unlock()
 
 	fmt.Println("= print scores")
+	for pk, rfpk := range db.byPubKey {
+		db.printScores(pk.String(), rfpk)
+	}
 	for pubkey, rfpk := range db.OrderedRelaysByPubKey {
-		fmt.Println("== relay scores for", pubkey)
-		for i, re := range rfpk.Entries {
-			fmt.Printf("  %3d :: %30s (%3d) ::> %12d\n", i, db.RelayBySerial[re.Relay], re.Relay, re.Sum())
-			// for i, ts := range re.Timestamps {
-			// 	fmt.Printf("                             %-10d %s\n", ts, hints.HintKey(i).String())
-			// }
-		}
+		db.printScores(pubkey, rfpk)
+	}
+}
+
+func (db *HintDB) printScores(pubkey string, rfpk RelaysForPubKey) {
+	fmt.Println("== relay scores for", pubkey)
+	for i, re := range rfpk.Entries {
+		fmt.Printf("  %3d :: %30s (%3d) ::> %12d\n", i, db.RelayBySerial[re.Relay], re.Relay, re.Sum())
+		// for i, ts := range re.Timestamps {
+		// 	fmt.Printf("                             %-10d %s\n", ts, hints.HintKey(i).String())
+		// }
 	}
 }
 
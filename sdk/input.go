@@ -7,9 +7,10 @@ import (
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip05"
 	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip21"
 )
 
-// InputToProfile turns any npub/nprofile/hex/nip05 input into a ProfilePointer (or nil).
+// InputToProfile turns any npub/nprofile/hex/nip05 input, or a nostr: URI, into a ProfilePointer (or nil).
 func InputToProfile(ctx context.Context, input string) *nostr.ProfilePointer {
 	// handle if it is a hex string
 	if len(input) == 64 {
@@ -18,14 +19,20 @@ func InputToProfile(ctx context.Context, input string) *nostr.ProfilePointer {
 		}
 	}
 
+	// handle nostr: URIs
+	if nip21.IsNostrURI(input) {
+		pointer, _ := nip21.Parse(input)
+		if pp, ok := pointer.(nostr.ProfilePointer); ok {
+			return &pp
+		}
+		return nil
+	}
+
 	// handle nip19 codes, if that's the case
-	prefix, data, _ := nip19.Decode(input)
-	switch prefix {
-	case "npub":
-		input = data.(string)
-		return &nostr.ProfilePointer{PublicKey: input}
-	case "nprofile":
-		pp := data.(nostr.ProfilePointer)
+	if pk, err := nip19.DecodeNpub(input); err == nil {
+		return &nostr.ProfilePointer{PublicKey: pk}
+	}
+	if pp, err := nip19.DecodeNprofile(input); err == nil {
 		return &pp
 	}
 
@@ -3,10 +3,9 @@ package sdk
 import (
 	"regexp"
 	"strconv"
-	"strings"
 
 	"github.com/nbd-wtf/go-nostr"
-	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip21"
 )
 
 type Reference struct {
@@ -34,26 +33,13 @@ func ParseReferences(evt *nostr.Event) []*Reference {
 
 		if ref[6] == -1 {
 			// didn't find a NIP-10 #[0] reference, so it's a NIP-27 mention
-			nip19code := content[ref[2]:ref[3]]
-
-			if prefix, data, err := nip19.Decode(nip19code); err == nil {
-				switch prefix {
-				case "npub":
-					reference.Profile = &nostr.ProfilePointer{
-						PublicKey: data.(string), Relays: []string{},
-					}
-				case "nprofile":
-					pp := data.(nostr.ProfilePointer)
-					reference.Profile = &pp
-				case "note":
-					reference.Event = &nostr.EventPointer{ID: data.(string), Relays: []string{}}
-				case "nevent":
-					evp := data.(nostr.EventPointer)
-					reference.Event = &evp
-				case "naddr":
-					addr := data.(nostr.EntityPointer)
-					reference.Entity = &addr
-				}
+			switch pointer, _ := nip21.Parse(reference.Text); p := pointer.(type) {
+			case nostr.ProfilePointer:
+				reference.Profile = &p
+			case nostr.EventPointer:
+				reference.Event = &p
+			case nostr.EntityPointer:
+				reference.Entity = &p
 			}
 		} else {
 			// it's a NIP-10 mention.
@@ -84,18 +70,13 @@ func ParseReferences(evt *nostr.Event) []*Reference {
 						Relays: relays,
 					}
 				case "a":
-					if parts := strings.Split(tag[1], ":"); len(parts) == 3 {
-						kind, _ := strconv.Atoi(parts[0])
+					if addr, err := nostr.ParseAddress(tag[1]); err == nil {
 						relays := make([]string, 0, 1)
 						if len(tag) > 2 && tag[2] != "" {
 							relays = append(relays, tag[2])
 						}
-						reference.Entity = &nostr.EntityPointer{
-							Identifier: parts[2],
-							PublicKey:  parts[1],
-							Kind:       kind,
-							Relays:     relays,
-						}
+						pointer := addr.Pointer(relays...)
+						reference.Entity = &pointer
 					}
 				}
 			}
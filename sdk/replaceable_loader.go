@@ -108,7 +108,7 @@ func (sys *System) batchLoadReplaceableEvents(
 
 			// insert this event at the desired position
 			pos := keyPositions[evt.PubKey] // @unchecked: it must succeed because it must be a key we passed
-			if results[pos].Data == nil || results[pos].Data.CreatedAt < evt.CreatedAt {
+			if results[pos].Data == nil || nostr.IsNewerVersion(evt, results[pos].Data) {
 				results[pos] = &dataloader.Result[*nostr.Event]{Data: evt}
 			}
 		case <-ctx.Done():
@@ -45,9 +45,7 @@ func (sys *System) FetchSpecificEvent(
 		}
 	case nostr.EntityPointer:
 		author = v.PublicKey
-		filter.Authors = []string{v.PublicKey}
-		filter.Tags = nostr.TagMap{}.SetLiterals("d", v.Identifier)
-		filter.Kinds = []int{v.Kind}
+		filter = v.Address().Filter()
 		relays = append(relays, v.Relays...)
 		relays = appendUnique(relays, sys.FallbackRelays.Next())
 		fallback = append(fallback, sys.FallbackRelays.Next(), sys.FallbackRelays.Next())
@@ -131,7 +129,9 @@ attempts:
 				go sys.FetchProfileMetadata(ctx, ie.PubKey)
 			})
 
-			successRelays = append(successRelays, ie.Relay.URL)
+			if ie.Relay != nil {
+				successRelays = append(successRelays, ie.Relay.URL)
+			}
 			if result == nil || ie.CreatedAt > result.CreatedAt {
 				result = ie.Event
 			}
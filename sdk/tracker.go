@@ -8,7 +8,7 @@ import (
 )
 
 func (sys *System) TrackEventHints(ie nostr.RelayEvent) {
-	if IsVirtualRelay(ie.Relay.URL) {
+	if ie.Relay == nil || IsVirtualRelay(ie.Relay.URL) {
 		return
 	}
 	if ie.Kind < 30000 && ie.Kind >= 20000 {
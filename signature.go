@@ -3,7 +3,6 @@
 package nostr
 
 import (
-	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 
@@ -16,28 +15,31 @@ import (
 // returns an error if the signature itself is invalid.
 func (evt Event) CheckSignature() (bool, error) {
 	// read and check pubkey
-	pk, err := hex.DecodeString(evt.PubKey)
+	pk, err := ParsePubKey(evt.PubKey)
 	if err != nil {
 		return false, fmt.Errorf("event pubkey '%s' is invalid hex: %w", evt.PubKey, err)
 	}
 
-	pubkey, err := schnorr.ParsePubKey(pk)
+	pubkey, err := schnorr.ParsePubKey(pk[:])
 	if err != nil {
 		return false, fmt.Errorf("event has invalid pubkey '%s': %w", evt.PubKey, err)
 	}
 
 	// read signature
-	s, err := hex.DecodeString(evt.Sig)
-	if err != nil {
+	var s [64]byte
+	if len(evt.Sig) != 128 {
+		return false, fmt.Errorf("signature '%s' has invalid size", evt.Sig)
+	}
+	if _, err := hex.Decode(s[:], []byte(evt.Sig)); err != nil {
 		return false, fmt.Errorf("signature '%s' is invalid hex: %w", evt.Sig, err)
 	}
-	sig, err := schnorr.ParseSignature(s)
+	sig, err := schnorr.ParseSignature(s[:])
 	if err != nil {
 		return false, fmt.Errorf("failed to parse signature: %w", err)
 	}
 
 	// check signature
-	hash := sha256.Sum256(evt.Serialize())
+	hash := evt.ComputeID()
 	return sig.Verify(hash[:], pubkey), nil
 }
 
@@ -56,7 +58,7 @@ func (evt *Event) Sign(secretKey string) error {
 	pkBytes := pk.SerializeCompressed()
 	evt.PubKey = hex.EncodeToString(pkBytes[1:])
 
-	h := sha256.Sum256(evt.Serialize())
+	h := evt.ComputeID()
 	sig, err := schnorr.Sign(sk, h[:], schnorr.FastSign())
 	if err != nil {
 		return err
@@ -67,3 +69,15 @@ func (evt *Event) Sign(secretKey string) error {
 
 	return nil
 }
+
+type batchKey = *btcec.PublicKey
+
+func parseBatchKey(pk PubKey) (batchKey, error) {
+	return schnorr.ParsePubKey(pk[:])
+}
+
+// verifyBatchItem reports whether the signature of a prepared item is valid.
+func verifyBatchItem(item batchItem) bool {
+	sig, err := schnorr.ParseSignature(item.sig[:])
+	return err == nil && sig.Verify(item.hash[:], item.key)
+}
@@ -0,0 +1,132 @@
+package nostr
+
+import (
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// batchChunkSize is how many signatures a worker takes at once, batches up to this size are verified
+// without spawning any.
+const batchChunkSize = 64
+
+type batchItem struct {
+	index int
+	hash  [32]byte
+	key   batchKey
+	sig   [64]byte
+}
+
+// VerifyBatch checks the signatures of many events at once, also making sure their ids match their contents.
+// It is meant for bulk downloads and imports, where there are lots of events from a few authors: each pubkey
+// is parsed only once and work is spread over all CPUs.
+//
+// Neither signature backend offers Schnorr batch verification (btcec doesn't have it and neither does
+// upstream libsecp256k1), so each signature is still verified on its own, exactly once, and invalid events
+// are known right away without bisecting.
+//
+// ok is true if all events are valid, otherwise badIndices has the (sorted) positions of the invalid ones.
+func VerifyBatch(events []*Event) (ok bool, badIndices []int) {
+	keys := make(map[PubKey]batchKey)
+	items := make([]batchItem, 0, len(events))
+	for i, evt := range events {
+		item, valid := prepareBatchItem(evt, keys)
+		if !valid {
+			badIndices = append(badIndices, i)
+			continue
+		}
+		item.index = i
+		items = append(items, item)
+	}
+
+	if len(items) <= batchChunkSize {
+		for _, item := range items {
+			if !verifyBatchItem(item) {
+				badIndices = append(badIndices, item.index)
+			}
+		}
+	} else {
+		badIndices = append(badIndices, verifyBatchItems(items)...)
+	}
+
+	if len(badIndices) == 0 {
+		return true, nil
+	}
+	slices.Sort(badIndices)
+	return false, badIndices
+}
+
+// verifyBatchItems verifies items in chunks over all CPUs and returns the indices of the invalid ones.
+func verifyBatchItems(items []batchItem) (badIndices []int) {
+	chunks := make(chan []batchItem)
+	go func() {
+		for chunk := range slices.Chunk(items, batchChunkSize) {
+			chunks <- chunk
+		}
+		close(chunks)
+	}()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	workers := min(runtime.GOMAXPROCS(0), (len(items)+batchChunkSize-1)/batchChunkSize)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var bad []int
+			for chunk := range chunks {
+				for _, item := range chunk {
+					if !verifyBatchItem(item) {
+						bad = append(bad, item.index)
+					}
+				}
+			}
+			mu.Lock()
+			badIndices = append(badIndices, bad...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return badIndices
+}
+
+// prepareBatchItem decodes everything that is needed for verification, pubkeys are cached in keys
+// (including the invalid ones, as nil).
+func prepareBatchItem(evt *Event, keys map[PubKey]batchKey) (batchItem, bool) {
+	pk, err := ParsePubKey(evt.PubKey)
+	if err != nil {
+		return batchItem{}, false
+	}
+	key, cached := keys[pk]
+	if !cached {
+		key, _ = parseBatchKey(pk)
+		keys[pk] = key
+	}
+	if key == nil {
+		return batchItem{}, false
+	}
+
+	// ids and signatures must be lowercase, like CheckID expects
+	if len(evt.Sig) != 128 || !isLowerHex(evt.Sig) || !isLowerHex(evt.ID) {
+		return batchItem{}, false
+	}
+	r, err := decode32ByteHex(evt.Sig[0:64])
+	if err != nil {
+		return batchItem{}, false
+	}
+	s, err := decode32ByteHex(evt.Sig[64:128])
+	if err != nil {
+		return batchItem{}, false
+	}
+
+	hash := evt.ComputeID()
+	if id, err := ParseID(evt.ID); err != nil || id != hash {
+		return batchItem{}, false
+	}
+
+	item := batchItem{hash: hash, key: key}
+	copy(item.sig[0:32], r[:])
+	copy(item.sig[32:64], s[:])
+	return item, true
+}
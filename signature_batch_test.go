@@ -0,0 +1,71 @@
+package nostr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func makeBatch(t testing.TB, n int) []*Event {
+	keys := []string{GeneratePrivateKey(), GeneratePrivateKey(), GeneratePrivateKey()}
+	events := make([]*Event, n)
+	for i := range events {
+		evt := &Event{Kind: 1, CreatedAt: Timestamp(1700000000 + i), Content: fmt.Sprintf("event %d", i)}
+		require.NoError(t, evt.Sign(keys[i%len(keys)]))
+		events[i] = evt
+	}
+	return events
+}
+
+func TestVerifyBatch(t *testing.T) {
+	t.Parallel()
+
+	ok, bad := VerifyBatch(nil)
+	require.True(t, ok)
+	require.Nil(t, bad)
+
+	events := makeBatch(t, 300)
+	ok, bad = VerifyBatch(events)
+	require.True(t, ok)
+	require.Nil(t, bad)
+
+	events[3].Content = "changed"                                 // id mismatch
+	events[70].Sig = events[71].Sig                               // valid signature, wrong event
+	events[150].PubKey = events[151].PubKey                       // someone else's key
+	events[151].Sig = events[151].Sig[:100]                       // malformed signature
+	events[299].PubKey = "not a key"                              // malformed pubkey
+	events[200].ID = strings.Repeat("0", 64)                      // id doesn't match
+	events[201].CreatedAt++                                       // signature (and id) don't match
+	events[202].Sig = events[202].Sig[64:] + events[202].Sig[:64] // broken signature
+	events[250].ID = strings.ToUpper(events[250].ID)              // uppercase id
+	events[251].Sig = strings.ToUpper(events[251].Sig)            // uppercase signature
+
+	ok, bad = VerifyBatch(events)
+	require.False(t, ok)
+	require.Equal(t, []int{3, 70, 150, 151, 200, 201, 202, 250, 251, 299}, bad)
+
+	// small batches are verified the same way
+	ok, bad = VerifyBatch(events[199:203])
+	require.False(t, ok)
+	require.Equal(t, []int{1, 2, 3}, bad)
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	events := makeBatch(b, 1000)
+
+	b.Run("CheckSignature", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, evt := range events {
+				evt.CheckSignature()
+			}
+		}
+	})
+
+	b.Run("VerifyBatch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			VerifyBatch(events)
+		}
+	})
+}
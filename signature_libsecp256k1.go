@@ -12,7 +12,6 @@ import "C"
 
 import (
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -37,7 +36,7 @@ func (evt Event) CheckSignature() (bool, error) {
 		return false, fmt.Errorf("event signature '%s' is invalid hex: %w", evt.Sig, err)
 	}
 
-	msg := sha256.Sum256(evt.Serialize())
+	msg := evt.ComputeID()
 
 	var xonly C.secp256k1_xonly_pubkey
 	if C.secp256k1_xonly_pubkey_parse(globalSecp256k1Context, &xonly, (*C.uchar)(unsafe.Pointer(&pk[0]))) != 1 {
@@ -70,7 +69,7 @@ func (evt *Event) Sign(secretKey string, signOpts ...schnorr.SignOption) error {
 	C.secp256k1_xonly_pubkey_serialize(globalSecp256k1Context, (*C.uchar)(unsafe.Pointer(&pk[0])), &xonly)
 	evt.PubKey = hex.EncodeToString(pk[:])
 
-	h := sha256.Sum256(evt.Serialize())
+	h := evt.ComputeID()
 
 	var sig [64]byte
 	var random [32]byte
@@ -93,3 +92,18 @@ func init() {
 		panic("failed to create secp256k1 context")
 	}
 }
+
+type batchKey = *C.secp256k1_xonly_pubkey
+
+func parseBatchKey(pk PubKey) (batchKey, error) {
+	var xonly C.secp256k1_xonly_pubkey
+	if C.secp256k1_xonly_pubkey_parse(globalSecp256k1Context, &xonly, (*C.uchar)(unsafe.Pointer(&pk[0]))) != 1 {
+		return nil, fmt.Errorf("failed to parse xonly pubkey")
+	}
+	return &xonly, nil
+}
+
+// verifyBatchItem reports whether the signature of a prepared item is valid.
+func verifyBatchItem(item batchItem) bool {
+	return C.secp256k1_schnorrsig_verify(globalSecp256k1Context, (*C.uchar)(unsafe.Pointer(&item.sig[0])), (*C.uchar)(unsafe.Pointer(&item.hash[0])), 32, item.key) == 1
+}
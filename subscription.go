@@ -36,6 +36,9 @@ type Subscription struct {
 	eosed  atomic.Bool
 	cancel context.CancelFunc
 
+	// set by WithDropExpired
+	dropExpired bool
+
 	// this keeps track of the events we've received before the EOSE that we must dispatch before
 	// closing the EndOfStoredEvents channel
 	storedwg sync.WaitGroup
@@ -58,7 +61,15 @@ type WithLabel string
 
 func (_ WithLabel) IsSubscriptionOption() {}
 
-var _ SubscriptionOption = (WithLabel)("")
+// WithDropExpired makes the subscription ignore events with a NIP-40 expiration in the past.
+type WithDropExpired struct{}
+
+func (_ WithDropExpired) IsSubscriptionOption() {}
+
+var (
+	_ SubscriptionOption = (WithLabel)("")
+	_ SubscriptionOption = WithDropExpired{}
+)
 
 func (sub *Subscription) start() {
 	<-sub.Context.Done()
@@ -152,7 +163,7 @@ func (sub *Subscription) Fire() error {
 	if sub.countResult == nil {
 		reqb, _ = ReqEnvelope{sub.id, sub.Filters}.MarshalJSON()
 	} else {
-		reqb, _ = CountEnvelope{sub.id, sub.Filters, nil, nil}.MarshalJSON()
+		reqb, _ = CountEnvelope{SubscriptionID: sub.id, Filters: sub.Filters}.MarshalJSON()
 	}
 
 	sub.live.Store(true)
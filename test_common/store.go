@@ -0,0 +1,103 @@
+package test_common
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// MemoryStore keeps events in memory, in insertion order. It satisfies nostr.RelayStore
+// and the eventstore.Store interface, so tests can use it in place of a real database.
+type MemoryStore struct {
+	mu     sync.Mutex
+	Events []*nostr.Event
+
+	// Publish fails once, with ErrStoreFull, when the store holds this many events
+	FailPublishAt int
+
+	// DeleteEvent fails with ErrStoreBusy while this is above zero, decreasing it
+	FailDeletes int
+}
+
+var (
+	ErrStoreFull = errors.New("disk full")
+	ErrStoreBusy = errors.New("store is busy")
+)
+
+func (s *MemoryStore) Init() error { return nil }
+func (s *MemoryStore) Close()      {}
+
+func (s *MemoryStore) Publish(ctx context.Context, evt nostr.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.FailPublishAt > 0 && len(s.Events) == s.FailPublishAt {
+		s.FailPublishAt = 0
+		return ErrStoreFull
+	}
+	s.Events = append(s.Events, &evt)
+	return nil
+}
+
+func (s *MemoryStore) SaveEvent(ctx context.Context, evt *nostr.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.index(evt.ID); i != -1 {
+		s.Events[i] = evt
+	} else {
+		s.Events = append(s.Events, evt)
+	}
+	return nil
+}
+
+func (s *MemoryStore) ReplaceEvent(ctx context.Context, evt *nostr.Event) error {
+	return s.SaveEvent(ctx, evt)
+}
+
+func (s *MemoryStore) DeleteEvent(ctx context.Context, evt *nostr.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.FailDeletes > 0 {
+		s.FailDeletes--
+		return ErrStoreBusy
+	}
+	if i := s.index(evt.ID); i != -1 {
+		s.Events = slices.Delete(s.Events, i, i+1)
+	}
+	return nil
+}
+
+func (s *MemoryStore) QueryEvents(ctx context.Context, filter nostr.Filter) (chan *nostr.Event, error) {
+	res, _ := s.QuerySync(ctx, filter)
+	ch := make(chan *nostr.Event, len(res))
+	for _, evt := range res {
+		ch <- evt
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (s *MemoryStore) QuerySync(ctx context.Context, filter nostr.Filter) ([]*nostr.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []*nostr.Event
+	for _, evt := range s.Events {
+		if filter.Matches(evt) {
+			res = append(res, evt)
+		}
+	}
+	return res, nil
+}
+
+// Has tells whether an event with the given id is stored.
+func (s *MemoryStore) Has(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index(id) != -1
+}
+
+func (s *MemoryStore) index(id string) int {
+	return slices.IndexFunc(s.Events, func(evt *nostr.Event) bool { return evt.ID == id })
+}